@@ -0,0 +1,67 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const ErrorCodeUnauthorized = -32001
+
+// AuthBefore returns a BeforeFunc that takes the Bearer token from the
+// Authorization header and passes it to verify, which usually stores the
+// authenticated principal in the returned context. A missing token or a
+// verify error fails the call with ErrorCodeUnauthorized, unless verify
+// returns a *Error of its own.
+func AuthBefore(verify func(ctx context.Context, token string) (context.Context, error)) BeforeFunc {
+	return func(ctx context.Context, r *http.Request) (context.Context, error) {
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return ctx, NewError(ErrorCodeUnauthorized, "unauthorized", "missing bearer token")
+		}
+		newCtx, err := verify(ctx, token)
+		if err != nil {
+			var rpcErr *Error
+			if errors.As(err, &rpcErr) {
+				return ctx, err
+			}
+			return ctx, NewError(ErrorCodeUnauthorized, "unauthorized", err.Error())
+		}
+		return newCtx, nil
+	}
+}
+
+// WithAuthMiddleware checks every call with fn before the Before funcs run.
+// A non-nil error fails the call with a -32000 "unauthorized" error, or
+// the code set by WithAuthErrorCode, carrying the message as data, unless
+// fn returns a *Error of its own.
+func WithAuthMiddleware(fn func(ctx context.Context, r *http.Request) error) Option {
+	return func(o *Options) {
+		o.auth = append(o.auth, fn)
+	}
+}
+
+// WithAuthErrorCode sets the code of errors from WithAuthMiddleware.
+func WithAuthErrorCode(code int) Option {
+	return func(o *Options) {
+		o.authErrorCode = code
+	}
+}
+
+func (o *Options) authorize(ctx context.Context, r *http.Request) error {
+	for _, fn := range o.auth {
+		if err := fn(ctx, r); err != nil {
+			var rpcErr *Error
+			if errors.As(err, &rpcErr) {
+				return err
+			}
+			code := o.authErrorCode
+			if code == 0 {
+				code = jsonRPCServerError
+			}
+			return NewError(code, "unauthorized", err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package jsonrpc
+
+import (
+	"errors"
+	"math/rand"
+	"sync/atomic"
+)
+
+var ErrNoTargets = errors.New("jsonrpc: load balancer needs at least one target")
+
+// LoadBalancer picks the target URL for each call. Implementations must be
+// safe for concurrent use.
+type LoadBalancer interface {
+	Next() string
+}
+
+// WithLoadBalancer sends every call to the target returned by lb.Next()
+// instead of the client's URL. Requests with their own Target and clients
+// created with NewClientWithURLFactory are not balanced.
+func WithLoadBalancer(lb LoadBalancer) ClientOption {
+	return func(o *clientOptions) {
+		o.balancer = lb
+	}
+}
+
+type roundRobinBalancer struct {
+	targets []string
+	next    uint64
+}
+
+// NewRoundRobinBalancer cycles through targets in order. It fails with
+// ErrNoTargets if targets is empty.
+func NewRoundRobinBalancer(targets []string) (LoadBalancer, error) {
+	if len(targets) == 0 {
+		return nil, ErrNoTargets
+	}
+	return &roundRobinBalancer{targets: append([]string(nil), targets...)}, nil
+}
+
+func (b *roundRobinBalancer) Next() string {
+	n := atomic.AddUint64(&b.next, 1) - 1
+	return b.targets[n%uint64(len(b.targets))]
+}
+
+type randomBalancer struct {
+	targets []string
+}
+
+// NewRandomBalancer picks one of targets at random for every call. It
+// fails with ErrNoTargets if targets is empty.
+func NewRandomBalancer(targets []string) (LoadBalancer, error) {
+	if len(targets) == 0 {
+		return nil, ErrNoTargets
+	}
+	return &randomBalancer{targets: append([]string(nil), targets...)}, nil
+}
+
+func (b *randomBalancer) Next() string {
+	return b.targets[rand.Intn(len(b.targets))]
+}
@@ -0,0 +1,204 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var ErrBatchTooLarge = errors.New("jsonrpc: batch too large")
+
+// ErrChunkFailed is wrapped, together with the cause, by the entry errors
+// of a split batch or target group whose call failed.
+var ErrChunkFailed = errors.New("jsonrpc: chunk failed")
+
+// WithClientBatchSizeLimit makes Execute fail with ErrBatchTooLarge, without
+// sending anything, when it is given more than max requests. It mirrors
+// the server's WithBatchSizeLimit and is checked before any splitting by
+// WithClientMaxBatchSize.
+func WithClientBatchSizeLimit(max int) ClientOption {
+	return func(o *clientOptions) {
+		o.batchLimit = max
+	}
+}
+
+// WithClientMaxBatchSize splits Execute calls with more than n requests
+// into several HTTP batches of at most n entries. Results are merged back
+// into a single BatchResult in the original order. If some of the calls
+// fail, Execute returns the merged result together with GroupErrors.
+func WithClientMaxBatchSize(n int) ClientOption {
+	return func(o *clientOptions) {
+		o.maxBatch = n
+	}
+}
+
+// WithBatchConcurrency sets how many split batches may be in flight at
+// once. The default of 1 sends them sequentially.
+func WithBatchConcurrency(workers int) ClientOption {
+	return func(o *clientOptions) {
+		o.workers = workers
+	}
+}
+
+// partition groups request indexes into the HTTP calls Execute will make.
+// Requests with an idempotency key, and all requests in 1.0 or GET mode,
+// get a call of their own.
+func (c *Client) partition(requests []Requester) [][]int {
+	var groups [][]int
+	plain := make([]int, 0, len(requests))
+	for i, r := range requests {
+		if idempotencyKey(r) != "" || c.unbatched() {
+			groups = append(groups, []int{i})
+		} else {
+			plain = append(plain, i)
+		}
+	}
+	size := c.opts.maxBatch
+	if len(groups) == 0 && (size <= 0 || len(requests) <= size) {
+		return nil
+	}
+	if size <= 0 {
+		size = len(plain)
+	}
+	for start := 0; start < len(plain); start += size {
+		end := start + size
+		if end > len(plain) {
+			end = len(plain)
+		}
+		groups = append(groups, plain[start:end])
+	}
+	return groups
+}
+
+func forEachLimit(n, workers int, f func(i int)) {
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+		return
+	}
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			f(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func (c *Client) executeGroups(ctx context.Context, requests []Requester, groups [][]int) (*BatchResult, error) {
+	return c.runGroups(ctx, requests, groups, c.execute)
+}
+
+// runGroups sends every group with run and merges the results in the
+// original order. A failed group fills its entries with -32000 errors
+// wrapping ErrChunkFailed and the cause, and the merged result comes back
+// together with GroupErrors so callers can still use the other entries.
+func (c *Client) runGroups(ctx context.Context, requests []Requester, groups [][]int, run func(context.Context, []Requester) (*BatchResult, error)) (*BatchResult, error) {
+	results := make([]*BatchResult, len(groups))
+	errs := make(GroupErrors, len(groups))
+	forEachLimit(len(groups), c.opts.workers, func(g int) {
+		group := make([]Requester, len(groups[g]))
+		for j, i := range groups[g] {
+			group[j] = requests[i]
+		}
+		results[g], errs[g] = run(ctx, group)
+	})
+	merged := &BatchResult{ctx: ctx, results: make([]any, len(requests))}
+	if c.opts.rawResults {
+		merged.raw = make([]json.RawMessage, len(requests))
+	}
+	var failed bool
+	for g, group := range groups {
+		if errs[g] != nil {
+			failed = true
+			var resultErr *ResultError
+			if errors.As(errs[g], &resultErr) {
+				resultErr.Index = group[resultErr.Index]
+			}
+		}
+		if results[g] == nil {
+			err := fmt.Errorf("%w: %w", ErrChunkFailed, errs[g])
+			for _, i := range group {
+				merged.results[i] = &Error{code: jsonRPCServerError, message: err.Error(), err: err}
+			}
+			continue
+		}
+		for j, i := range group {
+			merged.results[i] = results[g].results[j]
+			if merged.raw != nil {
+				merged.raw[i] = results[g].raw[j]
+			}
+		}
+		merged.ctx = results[g].ctx
+	}
+	if failed {
+		return merged, errs
+	}
+	return merged, nil
+}
+
+const defaultConcurrentWorkers = 4
+
+// GroupErrors is returned by ExecuteConcurrent, and by Execute for split
+// or multi-target batches, when at least one group failed. It is indexed
+// like the groups passed in, with nil entries for groups that succeeded.
+type GroupErrors []error
+
+func (e GroupErrors) Error() string {
+	var failed []string
+	for i, err := range e {
+		if err != nil {
+			failed = append(failed, "group "+strconv.Itoa(i)+": "+err.Error())
+		}
+	}
+	return "jsonrpc: " + strings.Join(failed, "; ")
+}
+
+func (e GroupErrors) Unwrap() []error {
+	var errs []error
+	for _, err := range e {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// ExecuteConcurrent sends each group as its own call, running up to the
+// WithBatchConcurrency limit (4 by default) at once. Results are indexed
+// like groups; a failed group leaves a nil result and its error in the
+// returned GroupErrors.
+func (c *Client) ExecuteConcurrent(ctx context.Context, groups ...[]Requester) ([]*BatchResult, error) {
+	workers := c.opts.workers
+	if workers <= 0 {
+		workers = defaultConcurrentWorkers
+	}
+	results := make([]*BatchResult, len(groups))
+	errs := make(GroupErrors, len(groups))
+	var failed bool
+	forEachLimit(len(groups), workers, func(g int) {
+		results[g], errs[g] = c.ExecuteWithContext(ctx, groups[g]...)
+	})
+	for _, err := range errs {
+		if err != nil {
+			failed = true
+			break
+		}
+	}
+	if failed {
+		return results, errs
+	}
+	return results, nil
+}
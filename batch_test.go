@@ -0,0 +1,251 @@
+package jsonrpc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/555f/jsonrpc"
+)
+
+func newCountingServer(t testing.TB) (*httptest.Server, *int64) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	var calls int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		s.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &calls
+}
+
+func echoRequests(n int) []jsonrpc.Requester {
+	requests := make([]jsonrpc.Requester, n)
+	for i := range requests {
+		requests[i] = &echoRequest{method: "echo", params: strconv.Itoa(i)}
+	}
+	return requests
+}
+
+func TestClientMaxBatchSize(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		ts, calls := newCountingServer(t)
+		c := jsonrpc.NewClient(ts.URL, jsonrpc.WithClientMaxBatchSize(3), jsonrpc.WithBatchConcurrency(workers))
+		result, err := c.Execute(echoRequests(7)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *calls != 3 {
+			t.Fatalf("workers=%d: made %d HTTP calls, want 3", workers, *calls)
+		}
+		if result.Len() != 7 {
+			t.Fatalf("got %d results, want 7", result.Len())
+		}
+		for i := 0; i < 7; i++ {
+			if got := result.At(i); got != strconv.Itoa(i) {
+				t.Fatalf("workers=%d: result %d = %v", workers, i, got)
+			}
+		}
+	}
+}
+
+func TestClientPartialBatchResult(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	var calls int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&calls, 1) == 2 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		s.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithClientMaxBatchSize(2))
+	result, err := c.Execute(echoRequests(5)...)
+	var groupErrs jsonrpc.GroupErrors
+	if !errors.As(err, &groupErrs) || groupErrs[0] != nil || groupErrs[1] == nil || groupErrs[2] != nil {
+		t.Fatalf("got %v, want the second chunk to fail", err)
+	}
+	for i, want := range []any{"0", "1", nil, nil, "4"} {
+		if want == nil {
+			if !errors.Is(result.Error(i), jsonrpc.ErrChunkFailed) {
+				t.Fatalf("entry %d: got %v, want ErrChunkFailed", i, result.Error(i))
+			}
+		} else if got := result.At(i); got != want {
+			t.Fatalf("entry %d = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestClientExecuteConcurrent(t *testing.T) {
+	ts, calls := newCountingServer(t)
+	c := jsonrpc.NewClient(ts.URL)
+	unencodable := &echoRequest{method: "echo", params: func() {}}
+	groups := [][]jsonrpc.Requester{echoRequests(2), {unencodable}, echoRequests(3)}
+	results, err := c.ExecuteConcurrent(context.Background(), groups...)
+	var errs jsonrpc.GroupErrors
+	if !errors.As(err, &errs) || errs[0] != nil || errs[1] == nil || errs[2] != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if *calls != 2 {
+		t.Fatalf("made %d HTTP calls, want 2", *calls)
+	}
+	if results[1] != nil || results[0].Len() != 2 || results[2].At(2) != "2" {
+		t.Fatalf("unexpected results %v", results)
+	}
+}
+
+func BenchmarkClientExecuteConcurrent(b *testing.B) {
+	ts, _ := newCountingServer(b)
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithBatchConcurrency(8))
+	groups := make([][]jsonrpc.Requester, 16)
+	for i := range groups {
+		groups[i] = echoRequests(10)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.ExecuteConcurrent(context.Background(), groups...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestClientBatchSizeLimit(t *testing.T) {
+	ts, calls := newCountingServer(t)
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithClientBatchSizeLimit(2))
+	if _, err := c.Execute(echoRequests(3)...); !errors.Is(err, jsonrpc.ErrBatchTooLarge) {
+		t.Fatalf("got %v, want ErrBatchTooLarge", err)
+	}
+	if *calls != 0 {
+		t.Fatalf("made %d HTTP calls, want 0", *calls)
+	}
+	if _, err := c.Execute(echoRequests(2)...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type intRequest struct {
+	echoRequest
+}
+
+func (r *intRequest) MakeResult(data []byte) (any, error) {
+	var n int
+	err := json.Unmarshal(data, &n)
+	return n, err
+}
+
+func TestClientResultError(t *testing.T) {
+	ts, _ := newCountingServer(t)
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithClientMaxBatchSize(2))
+	requests := append(echoRequests(4), &intRequest{echoRequest{method: "echo", params: "not a number"}})
+	_, err := c.Execute(requests...)
+	var resultErr *jsonrpc.ResultError
+	if !errors.As(err, &resultErr) {
+		t.Fatalf("got %v, want *jsonrpc.ResultError", err)
+	}
+	if resultErr.Method != "echo" || resultErr.Index != 4 || string(resultErr.Raw) != `"not a number"` {
+		t.Fatalf("unexpected ResultError %+v", resultErr)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("cause was not kept: %v", err)
+	}
+}
+
+func TestClientCache(t *testing.T) {
+	ts, calls := newCountingServer(t)
+	cache := jsonrpc.NewInMemoryIdempotencyCache(time.Minute)
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithCache(cache, map[string]time.Duration{"echo": time.Minute, "missing": time.Minute}))
+
+	if _, err := c.Execute(&echoRequest{method: "echo", params: "chainId"}); err != nil {
+		t.Fatal(err)
+	}
+	result, err := c.Execute(&echoRequest{method: "echo", params: "chainId"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *calls != 1 || result.At(0) != "chainId" {
+		t.Fatalf("all-hit batch made %d calls, result %v", *calls, result.At(0))
+	}
+
+	result, err = c.Execute(
+		&echoRequest{method: "echo", params: "other"},
+		&echoRequest{method: "echo", params: "chainId"},
+		&echoRequest{method: "missing"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *calls != 2 || result.At(0) != "other" || result.At(1) != "chainId" || result.Error(2) == nil {
+		t.Fatalf("calls=%d results %v %v %v", *calls, result.At(0), result.At(1), result.Error(2))
+	}
+	if _, err := c.Execute(&echoRequest{method: "missing"}); err != nil {
+		t.Fatal(err)
+	}
+	if *calls != 3 {
+		t.Fatalf("error response was served from cache")
+	}
+}
+
+func TestClientConcurrentIDs(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	var duplicates int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var requests []struct {
+			ID json.RawMessage `json:"id"`
+		}
+		_ = json.Unmarshal(body, &requests)
+		mu.Lock()
+		for _, req := range requests {
+			if seen[string(req.ID)] {
+				duplicates++
+			}
+			seen[string(req.ID)] = true
+		}
+		mu.Unlock()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		s.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	c := jsonrpc.NewClient(ts.URL)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 10; n++ {
+				result, err := c.Execute(echoRequests(5)...)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				for i := 0; i < 5; i++ {
+					if got := result.At(i); got != strconv.Itoa(i) {
+						t.Errorf("result %d = %v", i, got)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if duplicates != 0 || len(seen) != 8*10*5 {
+		t.Fatalf("%d duplicate ids among %d", duplicates, len(seen))
+	}
+}
@@ -0,0 +1,101 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Cache stores encoded results. The cache returned by
+// NewInMemoryIdempotencyCache satisfies it as well.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+// WithCache answers calls to the methods in ttlByMethod from cache when
+// the same method was called with the same params within its ttl. Only
+// the misses are sent, and a batch of nothing but hits makes no call at
+// all. Error responses are never cached.
+func WithCache(cache Cache, ttlByMethod map[string]time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.cache = cache
+		o.cacheTTL = ttlByMethod
+	}
+}
+
+func (c *Client) cacheKey(request Requester) (string, time.Duration, bool) {
+	if isNotification(request) {
+		return "", 0, false
+	}
+	method, params := request.MakeRequest()
+	ttl, ok := c.opts.cacheTTL[method]
+	if !ok {
+		return "", 0, false
+	}
+	data, err := c.opts.codec.Marshal(params)
+	if err != nil {
+		return "", 0, false
+	}
+	return method + "\x00" + string(data), ttl, true
+}
+
+func (c *Client) executeCached(ctx context.Context, requests []Requester) (*BatchResult, error) {
+	result := &BatchResult{ctx: ctx, results: make([]any, len(requests))}
+	if c.opts.rawResults {
+		result.raw = make([]json.RawMessage, len(requests))
+	}
+	keys := make([]string, len(requests))
+	ttls := make([]time.Duration, len(requests))
+	var misses []int
+	for i, request := range requests {
+		key, ttl, ok := c.cacheKey(request)
+		if !ok {
+			misses = append(misses, i)
+			continue
+		}
+		keys[i], ttls[i] = key, ttl
+		data, hit := c.opts.cache.Get(key)
+		if !hit {
+			misses = append(misses, i)
+			continue
+		}
+		v, err := request.MakeResult(data)
+		if err != nil {
+			method, _ := request.MakeRequest()
+			return nil, &ResultError{Method: method, Index: i, Raw: data, Err: err}
+		}
+		result.results[i] = v
+		if result.raw != nil {
+			result.raw[i] = data
+		}
+	}
+	if len(misses) == 0 {
+		return result, nil
+	}
+
+	sent := make([]Requester, len(misses))
+	for j, i := range misses {
+		sent[j] = requests[i]
+	}
+	fetched, err := c.send(ctx, sent)
+	if err != nil {
+		var resultErr *ResultError
+		if errors.As(err, &resultErr) {
+			resultErr.Index = misses[resultErr.Index]
+		}
+		return nil, err
+	}
+	for j, i := range misses {
+		result.results[i] = fetched.results[j]
+		if result.raw != nil {
+			result.raw[i] = fetched.raw[j]
+		}
+		if keys[i] != "" && fetched.raw[j] != nil && fetched.Error(j) == nil {
+			c.opts.cache.Set(keys[i], fetched.raw[j], ttls[i])
+		}
+	}
+	result.ctx = fetched.ctx
+	return result, nil
+}
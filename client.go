@@ -3,21 +3,67 @@ package jsonrpc
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"reflect"
+	"sync"
 	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
 )
 
 type ClientBeforeFunc func(context.Context, *http.Request) context.Context
 type ClientAfterFunc func(context.Context, *http.Response, json.RawMessage) context.Context
 
+type RoundTripper func(ctx context.Context, payload []byte) (data []byte, err error)
+type RoundTripMiddlewareFunc = func(RoundTripper) RoundTripper
+
+type ExecuteFunc func(ctx context.Context, requests []Requester) (*BatchResult, error)
+type ClientMiddleware = func(ExecuteFunc) ExecuteFunc
+
 type clientOptions struct {
 	ctx        context.Context
 	before     []ClientBeforeFunc
 	after      []ClientAfterFunc
 	httpClient *http.Client
+	transport  Transport
+	roundTrip  []RoundTripMiddlewareFunc
+	middleware []ClientMiddleware
+	limiter    *rate.Limiter
+	observers  []ObserverFunc
+	maxBatch   int
+	workers    int
+	closeGrace time.Duration
+	batchLimit int
+	codec      Codec
+	logger     *slog.Logger
+	logOpts    logOptions
+	tlsConfig  *tls.Config
+	rawResults bool
+	version    string
+	strict     bool
+	cache      Cache
+	cacheTTL   map[string]time.Duration
+
+	defaultDeadline time.Duration
+	followRedirects bool
+	maxRedirects    int
+	balancer        LoadBalancer
+	onViolation     func(ctx context.Context, err error)
+	payloadHook     func(ctx context.Context, payload []byte) ([]byte, error)
+	httpMethod      string
+
+	keepaliveInterval time.Duration
+	keepaliveTimeout  time.Duration
+	onReconnect       func(ctx context.Context)
+	onKeepaliveError  func(err error)
+
+	idempotencyKeys bool
 }
 type ClientOption func(*clientOptions)
 
@@ -27,12 +73,42 @@ func WithHTTPClient(httpClient *http.Client) ClientOption {
 	}
 }
 
+// WithTLSConfig sets the TLS configuration used for HTTP calls. The HTTP
+// client's transport, or http.DefaultTransport, is cloned with cfg so its
+// other settings are kept and the original is left untouched. A transport
+// that is not an *http.Transport cannot be configured, and every call of
+// the client then fails saying so.
+func WithTLSConfig(cfg *tls.Config) ClientOption {
+	return func(o *clientOptions) {
+		o.tlsConfig = cfg
+	}
+}
+
+// WithRawResults makes BatchResult keep each entry's undecoded result,
+// available through Raw, RawAt and Decode. This is the default.
+func WithRawResults() ClientOption {
+	return func(o *clientOptions) {
+		o.rawResults = true
+	}
+}
+
+// WithoutRawResults drops the undecoded results once they are decoded, to
+// save memory on large responses.
+func WithoutRawResults() ClientOption {
+	return func(o *clientOptions) {
+		o.rawResults = false
+	}
+}
+
 func WithContext(ctx context.Context) ClientOption {
 	return func(o *clientOptions) {
 		o.ctx = ctx
 	}
 }
 
+// BeforeRequest registers funcs that run on every outgoing HTTP request.
+// The encoded body is already attached, so they may read it, for example
+// to sign it into a header; it is rewound before the request is sent.
 func BeforeRequest(before ...ClientBeforeFunc) ClientOption {
 	return func(o *clientOptions) {
 		o.before = append(o.before, before...)
@@ -45,6 +121,43 @@ func AfterRequest(after ...ClientAfterFunc) ClientOption {
 	}
 }
 
+// WithMiddleware wraps every Execute, with the first middleware outermost.
+// Middleware sees the requests before validation and splitting and the
+// merged result, so it may retry, short-circuit or time the whole call.
+func WithMiddleware(middleware ...ClientMiddleware) ClientOption {
+	return func(o *clientOptions) {
+		o.middleware = append(o.middleware, middleware...)
+	}
+}
+
+func WithRoundTripMiddleware(middleware ...RoundTripMiddlewareFunc) ClientOption {
+	return func(o *clientOptions) {
+		o.roundTrip = append(o.roundTrip, middleware...)
+	}
+}
+
+// WithPayloadHook lets hook inspect or replace the encoded request body,
+// for example to sign it, before it is sent. An error aborts the call.
+// The hook runs once per call, so round trip middleware such as retries
+// or compression receives the bytes it returned.
+func WithPayloadHook(hook func(ctx context.Context, payload []byte) ([]byte, error)) ClientOption {
+	return func(o *clientOptions) {
+		o.payloadHook = hook
+	}
+}
+
+func WithClientRateLimit(rps float64, burst int) ClientOption {
+	return func(o *clientOptions) {
+		o.limiter = rate.NewLimiter(rate.Limit(rps), burst)
+	}
+}
+
+func WithClientCodec(codec Codec) ClientOption {
+	return func(o *clientOptions) {
+		o.codec = codec
+	}
+}
+
 type Requester interface {
 	MakeRequest() (string, any)
 	MakeResult(data []byte) (any, error)
@@ -63,27 +176,61 @@ type requesterWithContext interface {
 }
 
 type clientReq struct {
-	ID      uint64 `json:"id"`
-	Version string `json:"jsonrpc"`
+	ID      any    `json:"id"`
+	Version string `json:"jsonrpc,omitempty"`
 	Method  string `json:"method"`
 	Params  any    `json:"params"`
 }
 
+type noParams struct{}
+
+// NoParams may be returned as the params of a Requester to leave the
+// params member out of the request entirely, for servers that reject
+// "params": null.
+var NoParams any = noParams{}
+
+// Positional builds by-position params, always encoded as a JSON array.
+func Positional(args ...any) []any {
+	if args == nil {
+		return []any{}
+	}
+	return args
+}
+
+func (r clientReq) MarshalJSON() ([]byte, error) {
+	var params *any
+	if r.Params != NoParams {
+		params = &r.Params
+	}
+	return json.Marshal(struct {
+		ID      any    `json:"id,omitempty"`
+		Version string `json:"jsonrpc,omitempty"`
+		Method  string `json:"method"`
+		Params  *any   `json:"params,omitempty"`
+	}{r.ID, r.Version, r.Method, params})
+}
+
 type clientResp struct {
-	ID      uint64          `json:"id"`
+	ID      json.RawMessage `json:"id"`
 	Version string          `json:"jsonrpc"`
 	Error   *clientError    `json:"error"`
 	Result  json.RawMessage `json:"result"`
 }
 
 type clientError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Data    any    `json:"data"`
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
 }
 
 type BatchResult struct {
+	ctx     context.Context
 	results []any
+	raw     []json.RawMessage
+}
+
+func (r *BatchResult) Context() context.Context {
+	return r.ctx
 }
 
 func (r *BatchResult) Error(i int) (err error) {
@@ -94,80 +241,295 @@ func (r *BatchResult) Error(i int) (err error) {
 	return nil
 }
 
+func (r *BatchResult) Errors() []error {
+	errs := make([]error, len(r.results))
+	for i := range r.results {
+		errs[i] = r.Error(i)
+	}
+	return errs
+}
+
+func (r *BatchResult) HasErrors() bool {
+	for i := range r.results {
+		if r.Error(i) != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *BatchResult) At(i int) any {
 	return r.results[i]
 }
+
+// RawAt returns the undecoded result of entry i. It is nil for entries
+// that failed and when the client was created with WithoutRawResults.
+func (r *BatchResult) RawAt(i int) json.RawMessage {
+	if r.raw == nil {
+		return nil
+	}
+	return r.raw[i]
+}
+
+// Raw is like RawAt but reports whether the bytes were kept.
+func (r *BatchResult) Raw(i int) ([]byte, bool) {
+	raw := r.RawAt(i)
+	return raw, raw != nil
+}
+
+// Decode decodes the raw result of entry i into dst, which may have a
+// different shape than the Requester's own result. It returns the entry's
+// *Error if it failed.
+func (r *BatchResult) Decode(i int, dst any) error {
+	if err := r.Error(i); err != nil {
+		return err
+	}
+	raw, ok := r.Raw(i)
+	if !ok {
+		return fmt.Errorf("jsonrpc: raw result %d was not kept", i)
+	}
+	return json.Unmarshal(raw, dst)
+}
+
 func (r *BatchResult) Len() int {
 	return len(r.results)
 }
 
+// Range calls f for each entry in order with either its result or its
+// *Error, stopping early when f returns false.
+func (r *BatchResult) Range(f func(i int, result any, err error) bool) {
+	for i, result := range r.results {
+		err := r.Error(i)
+		if err != nil {
+			result = nil
+		}
+		if !f(i, result, err) {
+			return
+		}
+	}
+}
+
+// ResultAt returns entry i of r as a T. It fails with the entry's *Error
+// when the call failed, or with a descriptive error when the result has a
+// different type.
+func ResultAt[T any](r *BatchResult, i int) (T, error) {
+	var zero T
+	if i < 0 || i >= len(r.results) {
+		return zero, fmt.Errorf("jsonrpc: result index %d out of range [0:%d]", i, len(r.results))
+	}
+	if err := r.Error(i); err != nil {
+		return zero, err
+	}
+	v, ok := r.results[i].(T)
+	if !ok {
+		return zero, fmt.Errorf("jsonrpc: result %d is %T, not %v", i, r.results[i], reflect.TypeOf((*T)(nil)).Elem())
+	}
+	return v, nil
+}
+
 type Client struct {
 	target      string
 	incrementID uint64
 	opts        *clientOptions
+	urlFactory  func(method string) string
+	err         error
+
+	ownsTransport bool
+	closeMu       sync.RWMutex
+	closed        bool
+	inflight      sync.WaitGroup
+	closeCtx      context.Context
+	cancelClose   context.CancelFunc
 }
 
 func (c *Client) autoIncrementID() uint64 {
 	return atomic.AddUint64(&c.incrementID, 1)
 }
 
-func (c *Client) doRequests(ctx context.Context, requests []Requester) (data []byte, idsIndex map[uint64]int, resp *http.Response, err error) {
-	c.incrementID = 0
-	req, err := http.NewRequest("POST", c.target, nil)
-	if err != nil {
-		return nil, nil, nil, err
-	}
-
-	req = req.WithContext(ctx)
+type clientCall struct {
+	ctx       context.Context
+	methods   []string
+	idsIndex  map[uint64]int
+	index     map[string]int
+	bytesSent int
+	data      []byte
+	meta      TransportMeta
+	errClass  string
+}
 
-	idsIndex = make(map[uint64]int, len(requests))
-	rpcRequests := make([]clientReq, len(requests))
-	for _, beforeFunc := range c.opts.before {
-		req = req.WithContext(beforeFunc(req.Context(), req))
+func (c *Client) doRequests(ctx context.Context, requests []Requester) (call *clientCall, err error) {
+	call = &clientCall{
+		ctx:      ctx,
+		methods:  make([]string, len(requests)),
+		idsIndex: make(map[uint64]int, len(requests)),
+		index:    make(map[string]int, len(requests)),
 	}
+	rpcRequests := make([]clientReq, len(requests))
+	before := append([]ClientBeforeFunc(nil), c.opts.before...)
+	var key string
 	for i, request := range requests {
 		if v, ok := request.(requesterWithContext); ok && v.Context() != nil {
-			req = req.WithContext(v.Context())
+			ctx = v.Context()
 		}
 		if r, ok := request.(RequesterWithBefore); ok {
-			for _, beforeFunc := range r.Before() {
-				req = req.WithContext(beforeFunc(req.Context(), req))
+			before = append(before, r.Before()...)
+		}
+		if k := idempotencyKey(request); k != "" {
+			if key != "" {
+				call.errClass = ErrorClassEncode
+				return call, errMultipleIdempotencyKeys
 			}
+			key = k
+			before = append(before, setIdempotencyKey(k))
 		}
 		methodName, params := request.MakeRequest()
-		r := clientReq{ID: c.autoIncrementID(), Version: "2.0", Method: methodName, Params: params}
-		idsIndex[r.ID] = i
+		call.methods[i] = methodName
+		if isNotification(request) {
+			r := clientReq{Version: Version, Method: methodName, Params: params}
+			if c.legacy() {
+				r.ID, r.Version, r.Params = json.RawMessage("null"), "", positionalParams(params)
+			}
+			rpcRequests[i] = r
+			continue
+		}
+		id, idKey, err := c.requestID(request)
+		if err != nil {
+			call.errClass = ErrorClassEncode
+			return call, err
+		}
+		if j, ok := call.index[idKey]; ok {
+			call.errClass = ErrorClassEncode
+			return call, fmt.Errorf("jsonrpc: request %d reuses the id of request %d", i, j)
+		}
+		call.index[idKey] = i
+		if n, ok := id.(uint64); ok {
+			call.idsIndex[n] = i
+		}
+		r := clientReq{ID: id, Version: Version, Method: methodName, Params: params}
+		if c.legacy() {
+			r.Version, r.Params = "", positionalParams(params)
+		}
 		rpcRequests[i] = r
 	}
+	if len(requests) > 0 && requestTarget(requests[0]) != "" {
+		ctx = contextWithTarget(ctx, requestTarget(requests[0]))
+	} else if c.urlFactory != nil {
+		var method string
+		if len(requests) == 1 {
+			method = call.methods[0]
+		}
+		ctx = contextWithTarget(ctx, c.urlFactory(method))
+	} else if c.opts.balancer != nil {
+		ctx = contextWithTarget(ctx, c.opts.balancer.Next())
+	}
+	if key == "" && c.opts.idempotencyKeys {
+		before = append(before, setIdempotencyKey(newUUID()))
+	}
+	call.ctx = ctx
 
+	var payload any = rpcRequests
+	if c.unbatched() && len(rpcRequests) == 1 {
+		payload = rpcRequests[0]
+	}
 	reqBuf := bytes.NewBuffer(nil)
-	if err := json.NewEncoder(reqBuf).Encode(rpcRequests); err != nil {
-		return nil, nil, nil, err
+	if err := c.opts.codec.NewEncoder(reqBuf).Encode(payload); err != nil {
+		call.errClass = ErrorClassEncode
+		return call, err
 	}
-	req.Body = io.NopCloser(reqBuf)
-	resp, err = c.opts.httpClient.Do(req)
-	if err != nil {
-		return nil, nil, nil, err
+	payloadBytes := reqBuf.Bytes()
+	if c.opts.payloadHook != nil {
+		if payloadBytes, err = c.opts.payloadHook(ctx, payloadBytes); err != nil {
+			call.errClass = ErrorClassEncode
+			return call, err
+		}
+	}
+	call.bytesSent = len(payloadBytes)
+	if c.opts.logger != nil {
+		c.logRequest(ctx, rpcRequests, call.bytesSent)
+	}
+	if err := c.wait(ctx, len(requests)); err != nil {
+		call.errClass = ErrorClassTransport
+		return call, err
+	}
+	roundTrip := func(ctx context.Context, payload []byte) ([]byte, error) {
+		body, meta, err := c.opts.transport.RoundTrip(ctx, payload)
+		call.meta = meta
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		stop := context.AfterFunc(ctx, func() { _ = body.Close() })
+		defer stop()
+		data, err := io.ReadAll(body)
+		if err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return data, err
+	}
+	start := time.Now()
+	call.data, err = middlewareChain(c.opts.roundTrip)(roundTrip)(contextWithClientBefore(ctx, before), payloadBytes)
+	if call.meta.Request != nil {
+		call.ctx = call.meta.Request.Context()
 	}
-	if resp.StatusCode != 200 {
-		return nil, nil, nil, errors.New(resp.Status)
+	if c.opts.logger != nil {
+		c.logResponse(call, time.Since(start), err)
 	}
-	var wb = make([]byte, 0, 10485760)
-	buf := bytes.NewBuffer(wb)
-	written, err := io.Copy(buf, resp.Body)
 	if err != nil {
-		return nil, nil, nil, err
+		call.errClass = ErrorClassTransport
+		var status int
+		if resp := call.meta.Response; resp != nil && resp.StatusCode != 200 {
+			call.errClass = ErrorClassHTTPStatus
+			status = resp.StatusCode
+		}
+		if ctx.Err() == nil {
+			err = &TransportError{StatusCode: status, Err: err}
+		}
+		return call, err
+	}
+	return call, nil
+}
+
+func (c *Client) wait(ctx context.Context, n int) error {
+	if c.opts.limiter == nil {
+		return nil
+	}
+	if err := c.opts.limiter.WaitN(ctx, n); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if _, ok := ctx.Deadline(); ok && n <= c.opts.limiter.Burst() {
+			return context.DeadlineExceeded
+		}
+		return err
 	}
-	data = wb[:written]
-	return
+	return nil
 }
 
 func (c *Client) RawExecute(requests ...Requester) ([]byte, map[uint64]int, *http.Response, error) {
 	return c.RawExecuteWithContext(context.TODO(), requests...)
 }
 
+// RawExecuteWithContext sends requests in a single call and returns the
+// undecoded response body. Client-level AfterRequest funcs run once with
+// the whole body; per-Requester After funcs only run under Execute.
 func (c *Client) RawExecuteWithContext(ctx context.Context, requests ...Requester) ([]byte, map[uint64]int, *http.Response, error) {
-	return c.doRequests(ctx, requests)
+	ctx, done, err := c.begin(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer done()
+	if err := validateRequests(requests); err != nil {
+		return nil, nil, nil, err
+	}
+	call, err := c.doRequests(ctx, requests)
+	if err != nil {
+		return nil, nil, call.meta.Response, err
+	}
+	ctx = call.ctx
+	for _, afterFunc := range c.opts.after {
+		ctx = afterFunc(ctx, call.meta.Response, call.data)
+	}
+	return call.data, call.idsIndex, call.meta.Response, nil
 }
 
 func (c *Client) Execute(requests ...Requester) (*BatchResult, error) {
@@ -175,50 +537,225 @@ func (c *Client) Execute(requests ...Requester) (*BatchResult, error) {
 }
 
 func (c *Client) ExecuteWithContext(ctx context.Context, requests ...Requester) (*BatchResult, error) {
-	data, idsIndex, resp, err := c.doRequests(ctx, requests)
+	ctx, done, err := c.begin(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer done()
+	if len(c.opts.middleware) > 0 {
+		return middlewareChain(c.opts.middleware)(c.executeRequests)(ctx, requests)
+	}
+	return c.executeRequests(ctx, requests)
+}
+
+func (c *Client) executeRequests(ctx context.Context, requests []Requester) (*BatchResult, error) {
+	if c.opts.batchLimit > 0 && len(requests) > c.opts.batchLimit {
+		return nil, ErrBatchTooLarge
+	}
+	if err := validateRequests(requests); err != nil {
+		return nil, err
+	}
+	if err := checkIDs(requests); err != nil {
+		return nil, err
+	}
+	if groups := byTarget(requests); groups != nil {
+		return c.executeTargets(ctx, requests, groups)
+	}
+	return c.executeBatch(ctx, requests)
+}
+
+func (c *Client) executeBatch(ctx context.Context, requests []Requester) (*BatchResult, error) {
+	groups := c.partition(requests)
+	if len(groups) <= 1 {
+		return c.execute(ctx, requests)
+	}
+	return c.executeGroups(ctx, requests, groups)
+}
+
+// Call sends a single request and decodes its result into result, which
+// should be a pointer or nil to discard it. A JSON-RPC error is returned
+// as the *Error itself.
+func (c *Client) Call(ctx context.Context, method string, params any, result any) error {
+	r := &GenericRequester{Method: method, Params: params, Result: result}
+	batch, err := c.ExecuteWithContext(ctx, r)
+	if err != nil {
+		return err
+	}
+	if err := batch.Error(0); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *Client) execute(ctx context.Context, requests []Requester) (*BatchResult, error) {
+	if c.opts.cache != nil {
+		return c.executeCached(ctx, requests)
+	}
+	return c.send(ctx, requests)
+}
+
+func (c *Client) send(ctx context.Context, requests []Requester) (*BatchResult, error) {
+	start := time.Now()
+	call, err := c.doRequests(ctx, requests)
+	var batchResult *BatchResult
+	if err == nil {
+		if batchResult, err = c.makeBatchResult(call, requests); err != nil {
+			call.errClass = ErrorClassDecode
+		}
+	}
+	if len(c.opts.observers) > 0 {
+		c.observe(time.Since(start), call, batchResult, err)
+	}
+	return batchResult, err
+}
+
+func (c *Client) makeBatchResult(call *clientCall, requests []Requester) (*BatchResult, error) {
+	resp := call.meta.Response
 	responses := make([]clientResp, len(requests))
-	if err := json.Unmarshal(data, &responses); err != nil {
+	if len(bytes.TrimSpace(call.data)) == 0 {
+		responses = nil
+	} else if c.singleResponse(call.data) {
+		responses = responses[:1]
+		if err := c.opts.codec.Unmarshal(call.data, &responses[0]); err != nil {
+			return nil, err
+		}
+	} else if err := c.opts.codec.Unmarshal(call.data, &responses); err != nil {
 		return nil, err
 	}
+	var invalid []error
+	if c.opts.strict {
+		var err error
+		if invalid, err = c.checkResponses(call.data, len(responses)); err != nil {
+			return nil, err
+		}
+	}
+	ctx := call.ctx
 	batchResult := &BatchResult{results: make([]any, len(requests))}
-	for _, response := range responses {
-		i := idsIndex[response.ID]
+	if c.opts.rawResults || c.opts.cache != nil {
+		batchResult.raw = make([]json.RawMessage, len(requests))
+	}
+	seen := make([]bool, len(requests))
+	for p, response := range responses {
+		i, ok := call.index[idKey(response.ID)]
+		if ok && seen[i] {
+			err := fmt.Errorf("%w: duplicate response id %s", ErrInvalidResponse, response.ID)
+			if c.opts.strict {
+				return nil, err
+			}
+			if c.opts.onViolation != nil {
+				c.opts.onViolation(ctx, err)
+			}
+			continue
+		}
+		if !ok {
+			err := fmt.Errorf("%w: response id %s matches no request", ErrInvalidResponse, responseIDString(response.ID))
+			if c.opts.strict {
+				return nil, err
+			}
+			if c.opts.onViolation != nil {
+				c.opts.onViolation(ctx, err)
+			}
+			continue
+		}
+		seen[i] = true
+		if invalid != nil && invalid[p] != nil {
+			batchResult.results[i] = invalid[p]
+			continue
+		}
 		if response.Error != nil {
-			batchResult.results[i] = &Error{
+			rpcErr := &Error{
 				code:    response.Error.Code,
 				message: response.Error.Message,
-				data:    response.Error.Data,
+				rawData: response.Error.Data,
+			}
+			if len(rpcErr.rawData) > 0 {
+				if err := c.opts.codec.Unmarshal(rpcErr.rawData, &rpcErr.data); err != nil {
+					return nil, err
+				}
 			}
+			batchResult.results[i] = rpcErr
 			continue
 		}
 		for _, afterFunc := range c.opts.after {
-			afterFunc(resp.Request.Context(), resp, response.Result)
+			ctx = afterFunc(ctx, resp, response.Result)
 		}
 		request := requests[i]
 		if v, ok := request.(RequesterWithAfter); ok {
 			for _, afterFunc := range v.After() {
-				afterFunc(resp.Request.Context(), resp, response.Result)
+				ctx = afterFunc(ctx, resp, response.Result)
 			}
 		}
 		result, err := request.MakeResult(response.Result)
 		if err != nil {
-			return nil, err
+			return nil, &ResultError{Method: call.methods[i], Index: i, Raw: response.Result, Err: err}
 		}
 		batchResult.results[i] = result
+		if batchResult.raw != nil {
+			batchResult.raw[i] = response.Result
+		}
 	}
+	batchResult.ctx = ctx
 	return batchResult, nil
 }
 
 func NewClient(target string, opts ...ClientOption) *Client {
-	c := &Client{target: target, opts: &clientOptions{}}
+	c := &Client{target: target, opts: &clientOptions{rawResults: true}}
+	c.closeCtx, c.cancelClose = context.WithCancel(context.Background())
 	for _, opt := range opts {
 		opt(c.opts)
 	}
 	if c.opts.httpClient == nil {
 		c.opts.httpClient = http.DefaultClient
 	}
+	// cloned is set once the HTTP transport is one created here, whose
+	// idle connections Close may drop.
+	var cloned bool
+	if c.opts.tlsConfig != nil {
+		transport, err := cloneHTTPTransport(c.opts.httpClient, "WithTLSConfig")
+		if err != nil {
+			c.err = err
+		} else {
+			transport.TLSClientConfig = c.opts.tlsConfig
+			httpClient := *c.opts.httpClient
+			httpClient.Transport = transport
+			c.opts.httpClient = &httpClient
+			cloned = true
+		}
+	}
+	if c.opts.codec == nil {
+		c.opts.codec = stdCodec{}
+	}
+	if c.opts.transport == nil {
+		target, httpClient, err := unixTarget(target, c.opts.httpClient)
+		if err != nil && c.err == nil {
+			c.err = err
+		}
+		cloned = cloned || httpClient != c.opts.httpClient
+		if c.opts.followRedirects {
+			client := *httpClient
+			client.CheckRedirect = func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			}
+			httpClient = &client
+		}
+		c.opts.transport = &httpTransport{
+			target:          target,
+			httpClient:      httpClient,
+			followRedirects: c.opts.followRedirects,
+			maxRedirects:    c.opts.maxRedirects,
+			method:          c.opts.httpMethod,
+			ownsConns:       cloned,
+		}
+		c.ownsTransport = true
+	}
+	return c
+}
+
+// NewClientWithURLFactory is like NewClient but asks factory for the
+// target of every call. A call with a single request passes its method
+// name; a batch passes "" and must get back the batch endpoint.
+func NewClientWithURLFactory(factory func(method string) string, opts ...ClientOption) *Client {
+	c := NewClient("", opts...)
+	c.urlFactory = factory
 	return c
 }
@@ -1,9 +1,913 @@
 package jsonrpc_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/555f/jsonrpc"
 )
 
+type echoRequest struct {
+	method string
+	params any
+	after  []jsonrpc.ClientAfterFunc
+}
+
+func (r *echoRequest) MakeRequest() (string, any) {
+	return r.method, r.params
+}
+
+func (r *echoRequest) MakeResult(data []byte) (any, error) {
+	var result any
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *echoRequest) After() []jsonrpc.ClientAfterFunc {
+	return r.after
+}
+
+func newEchoServer(t *testing.T) *httptest.Server {
+	s := jsonrpc.NewServer()
+	s.Register("echo", func(ctx context.Context, request interface{}) (interface{}, error) {
+		return request, nil
+	}, func(ctx context.Context, r *http.Request, params json.RawMessage) (any, error) {
+		var v any
+		err := json.Unmarshal(params, &v)
+		return v, err
+	})
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
 func TestClient(t *testing.T) {
 
 }
+
+type counterKey struct{}
+
+func TestClientAfterFuncContext(t *testing.T) {
+	ts := newEchoServer(t)
+	count := func(ctx context.Context, _ *http.Response, _ json.RawMessage) context.Context {
+		n, _ := ctx.Value(counterKey{}).(int)
+		return context.WithValue(ctx, counterKey{}, n+1)
+	}
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.AfterRequest(count))
+	result, err := c.Execute(
+		&echoRequest{method: "echo", params: 1, after: []jsonrpc.ClientAfterFunc{count}},
+		&echoRequest{method: "echo", params: 2},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := result.Context().Value(counterKey{}).(int); n != 3 {
+		t.Fatalf("after funcs saw %d calls, want 3", n)
+	}
+}
+
+type staticTransport struct {
+	payload []byte
+	body    string
+}
+
+func (t *staticTransport) RoundTrip(ctx context.Context, payload []byte) (io.ReadCloser, jsonrpc.TransportMeta, error) {
+	t.payload = payload
+	return io.NopCloser(strings.NewReader(t.body)), jsonrpc.TransportMeta{}, nil
+}
+
+func TestClientTransport(t *testing.T) {
+	transport := &staticTransport{body: `[{"id":1,"jsonrpc":"2.0","result":"pong"}]`}
+	c := jsonrpc.NewClient("", jsonrpc.WithTransport(transport))
+	result, err := c.Execute(&echoRequest{method: "ping"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.At(0); got != "pong" {
+		t.Fatalf("got result %v, want pong", got)
+	}
+	if !strings.Contains(string(transport.payload), `"method":"ping"`) {
+		t.Fatalf("unexpected payload %s", transport.payload)
+	}
+}
+
+func TestClientRoundTripMiddleware(t *testing.T) {
+	var calls int
+	s := jsonrpc.NewServer()
+	s.Register("echo", func(ctx context.Context, request interface{}) (interface{}, error) {
+		calls++
+		return request, nil
+	}, rawDecode)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	cache := map[string][]byte{}
+	caching := func(next jsonrpc.RoundTripper) jsonrpc.RoundTripper {
+		return func(ctx context.Context, payload []byte) ([]byte, error) {
+			if data, ok := cache[string(payload)]; ok {
+				return data, nil
+			}
+			data, err := next(ctx, payload)
+			if err == nil {
+				cache[string(payload)] = data
+			}
+			return data, err
+		}
+	}
+	for i := 0; i < 2; i++ {
+		c := jsonrpc.NewClient(ts.URL, jsonrpc.WithRoundTripMiddleware(caching))
+		result, err := c.Execute(&echoRequest{method: "echo", params: "hello"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := result.At(0); got != "hello" {
+			t.Fatalf("got result %v, want hello", got)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("endpoint called %d times, want 1", calls)
+	}
+}
+
+func TestClientRateLimit(t *testing.T) {
+	ts := newEchoServer(t)
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithClientRateLimit(1, 2))
+	if _, err := c.Execute(&echoRequest{method: "echo", params: 1}, &echoRequest{method: "echo", params: 2}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.ExecuteWithContext(ctx, &echoRequest{method: "echo", params: 3}, &echoRequest{method: "echo", params: 4})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestBatchResultRange(t *testing.T) {
+	transport := &staticTransport{body: `[
+		{"id":2,"jsonrpc":"2.0","error":{"code":-32601,"message":"not found"}},
+		{"id":1,"jsonrpc":"2.0","result":"ok"},
+		{"id":3,"jsonrpc":"2.0","result":"stop"}
+	]`}
+	c := jsonrpc.NewClient("", jsonrpc.WithTransport(transport))
+	result, err := c.Execute(&echoRequest{method: "a"}, &echoRequest{method: "b"}, &echoRequest{method: "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seen []int
+	result.Range(func(i int, v any, err error) bool {
+		seen = append(seen, i)
+		switch i {
+		case 0:
+			if v != "ok" || err != nil {
+				t.Errorf("entry 0 = %v, %v", v, err)
+			}
+		case 1:
+			if v != nil || err == nil {
+				t.Errorf("entry 1 = %v, %v", v, err)
+			}
+		}
+		return i < 1
+	})
+	if len(seen) != 2 {
+		t.Fatalf("Range visited %v, want it to stop after index 1", seen)
+	}
+	if !result.HasErrors() {
+		t.Fatal("HasErrors() = false, want true")
+	}
+	if errs := result.Errors(); len(errs) != 3 || errs[0] != nil || errs[1] == nil || errs[2] != nil {
+		t.Fatalf("unexpected Errors() %v", errs)
+	}
+}
+
+func TestClientClose(t *testing.T) {
+	started := make(chan struct{})
+	s := jsonrpc.NewServer()
+	s.Register("block", func(ctx context.Context, request interface{}) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, rawDecode)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithCloseGrace(20*time.Millisecond))
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.Execute(&echoRequest{method: "block"})
+		errc <- err
+	}()
+	<-started
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("in-flight call got %v, want context.Canceled", err)
+	}
+	if _, err := c.Execute(&echoRequest{method: "echo"}); !errors.Is(err, jsonrpc.ErrClientClosed) {
+		t.Fatalf("got %v, want ErrClientClosed", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+}
+
+type idleCountingTransport struct {
+	http.RoundTripper
+	closed int
+}
+
+func (t *idleCountingTransport) CloseIdleConnections() { t.closed++ }
+
+func TestClientCloseLeavesSharedHTTPClient(t *testing.T) {
+	transport := &idleCountingTransport{RoundTripper: http.DefaultTransport}
+	shared := &http.Client{Transport: transport}
+	if err := jsonrpc.NewClient("http://127.0.0.1:1", jsonrpc.WithHTTPClient(shared)).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if transport.closed != 0 {
+		t.Fatal("Close dropped idle connections of a shared http.Client")
+	}
+}
+
+func TestResultAt(t *testing.T) {
+	transport := &staticTransport{body: `[
+		{"id":1,"jsonrpc":"2.0","result":"ok"},
+		{"id":2,"jsonrpc":"2.0","error":{"code":-32601,"message":"not found"}}
+	]`}
+	c := jsonrpc.NewClient("", jsonrpc.WithTransport(transport))
+	result, err := c.Execute(&echoRequest{method: "a"}, &echoRequest{method: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err := jsonrpc.ResultAt[string](result, 0); err != nil || s != "ok" {
+		t.Fatalf("ResultAt[string](0) = %q, %v", s, err)
+	}
+	if _, err := jsonrpc.ResultAt[int](result, 0); err == nil || !strings.Contains(err.Error(), "not int") {
+		t.Fatalf("want type error, got %v", err)
+	}
+	var rpcErr *jsonrpc.Error
+	if _, err := jsonrpc.ResultAt[string](result, 1); !errors.As(err, &rpcErr) || rpcErr.Code() != -32601 {
+		t.Fatalf("want *jsonrpc.Error, got %v", err)
+	}
+}
+
+type keyedRequest struct {
+	echoRequest
+	key string
+}
+
+func (r *keyedRequest) IdempotencyKey() string {
+	return r.key
+}
+
+func TestClientPayloadHook(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		s.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	retry := func(next jsonrpc.RoundTripper) jsonrpc.RoundTripper {
+		return func(ctx context.Context, payload []byte) ([]byte, error) {
+			if _, err := next(ctx, payload); err != nil {
+				return nil, err
+			}
+			return next(ctx, payload)
+		}
+	}
+	var hooks int
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithRoundTripMiddleware(retry), jsonrpc.WithPayloadHook(func(ctx context.Context, payload []byte) ([]byte, error) {
+		hooks++
+		if bytes.Contains(payload, []byte("reject")) {
+			return nil, errors.New("rejected")
+		}
+		return append([]byte(" "), payload...), nil
+	}))
+	if _, err := c.Execute(&echoRequest{method: "echo", params: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if hooks != 1 || len(bodies) != 2 || bodies[0] != bodies[1] || !strings.HasPrefix(bodies[0], " [") {
+		t.Fatalf("hooks=%d bodies=%q", hooks, bodies)
+	}
+	if _, err := c.Execute(&echoRequest{method: "echo", params: "reject"}); err == nil || len(bodies) != 2 {
+		t.Fatalf("hook error did not abort the call: %v", err)
+	}
+}
+
+func TestClientIdempotencyKey(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	var keys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(jsonrpc.IdempotencyKeyHeader))
+		s.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	retry := func(next jsonrpc.RoundTripper) jsonrpc.RoundTripper {
+		return func(ctx context.Context, payload []byte) ([]byte, error) {
+			if _, err := next(ctx, payload); err != nil {
+				return nil, err
+			}
+			return next(ctx, payload)
+		}
+	}
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithRoundTripMiddleware(retry))
+	result, err := c.Execute(
+		&echoRequest{method: "echo", params: 0},
+		&keyedRequest{echoRequest{method: "echo", params: 1}, "a"},
+		&echoRequest{method: "echo", params: 2},
+		&keyedRequest{echoRequest{method: "echo", params: 3}, "b"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if got := result.At(i); got != float64(i) {
+			t.Fatalf("result %d = %v", i, got)
+		}
+	}
+	counts := map[string]int{}
+	for _, k := range keys {
+		counts[k]++
+	}
+	if len(keys) != 6 || counts[""] != 2 || counts["a"] != 2 || counts["b"] != 2 {
+		t.Fatalf("unexpected keys per attempt %q", keys)
+	}
+
+	_, _, _, err = c.RawExecute(&keyedRequest{echoRequest{method: "echo"}, "a"}, &keyedRequest{echoRequest{method: "echo"}, "b"})
+	if err == nil {
+		t.Fatal("RawExecute accepted two idempotency keys")
+	}
+}
+
+func TestClientRawExecuteAfterFunc(t *testing.T) {
+	ts := newEchoServer(t)
+	var body json.RawMessage
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.AfterRequest(func(ctx context.Context, resp *http.Response, data json.RawMessage) context.Context {
+		body = data
+		return ctx
+	}))
+	data, _, _, err := c.RawExecuteWithContext(context.Background(), &echoRequest{method: "echo", params: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) == 0 || string(body) != string(data) {
+		t.Fatalf("after func got %q, want the raw body %q", body, data)
+	}
+}
+
+func TestErrorDataAs(t *testing.T) {
+	transport := &staticTransport{body: `[
+		{"id":1,"jsonrpc":"2.0","error":{"code":-32000,"message":"no data"}},
+		{"id":2,"jsonrpc":"2.0","error":{"code":-32000,"message":"primitive","data":42}},
+		{"id":3,"jsonrpc":"2.0","error":{"code":3,"message":"reverted","data":{"reason":"low balance","details":{"need":10,"have":[1,2]}}}}
+	]`}
+	c := jsonrpc.NewClient("", jsonrpc.WithTransport(transport))
+	result, err := c.Execute(&echoRequest{method: "a"}, &echoRequest{method: "b"}, &echoRequest{method: "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := -1
+	if err := jsonrpc.ErrorDataAs(result.Error(0), &n); err != nil || n != -1 {
+		t.Fatalf("nil data: n=%d err=%v", n, err)
+	}
+	if err := jsonrpc.ErrorDataAs(fmt.Errorf("wrapped: %w", result.Error(1)), &n); err != nil || n != 42 {
+		t.Fatalf("primitive data: n=%d err=%v", n, err)
+	}
+	var revert struct {
+		Reason  string `json:"reason"`
+		Details struct {
+			Need int   `json:"need"`
+			Have []int `json:"have"`
+		} `json:"details"`
+	}
+	if err := jsonrpc.ErrorDataAs(result.Error(2), &revert); err != nil {
+		t.Fatal(err)
+	}
+	if revert.Reason != "low balance" || revert.Details.Need != 10 || len(revert.Details.Have) != 2 {
+		t.Fatalf("nested data decoded as %+v", revert)
+	}
+	if err := jsonrpc.ErrorDataAs(errors.New("plain"), &n); err == nil {
+		t.Fatal("want an error for a chain without *jsonrpc.Error")
+	}
+}
+
+func TestBatchResultRawAt(t *testing.T) {
+	body := `[{"id":1,"jsonrpc":"2.0","result":{"a": 1}},{"id":2,"jsonrpc":"2.0","error":{"code":1,"message":"x"}}]`
+	c := jsonrpc.NewClient("", jsonrpc.WithTransport(&staticTransport{body: body}), jsonrpc.WithoutRawResults())
+	result, err := c.Execute(&echoRequest{method: "a"}, &echoRequest{method: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw, ok := result.Raw(0); ok {
+		t.Fatalf("raw results kept with WithoutRawResults: %s", raw)
+	}
+
+	c = jsonrpc.NewClient("", jsonrpc.WithTransport(&staticTransport{body: body}))
+	result, err = c.Execute(&echoRequest{method: "a"}, &echoRequest{method: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw := string(result.RawAt(0)); raw != `{"a": 1}` {
+		t.Fatalf("RawAt(0) = %s", raw)
+	}
+	if raw := result.RawAt(1); raw != nil {
+		t.Fatalf("RawAt(1) = %s, want nil for an error entry", raw)
+	}
+	var decoded struct{ A int }
+	if err := result.Decode(0, &decoded); err != nil || decoded.A != 1 {
+		t.Fatalf("Decode(0) = %+v, %v", decoded, err)
+	}
+	if err := result.Decode(1, &decoded); err == nil || err.Error() != "x" {
+		t.Fatalf("Decode(1) = %v, want the entry error", err)
+	}
+}
+
+func TestClientParams(t *testing.T) {
+	transport := &staticTransport{body: `[{"id":1,"jsonrpc":"2.0","result":null},{"id":2,"jsonrpc":"2.0","result":null}]`}
+	c := jsonrpc.NewClient("", jsonrpc.WithTransport(transport))
+	if _, err := c.Execute(
+		&echoRequest{method: "getblock", params: jsonrpc.Positional("abc", 2)},
+		&echoRequest{method: "ping", params: jsonrpc.NoParams},
+	); err != nil {
+		t.Fatal(err)
+	}
+	var sent []map[string]json.RawMessage
+	if err := json.Unmarshal(transport.payload, &sent); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(sent[0]["params"]); got != `["abc",2]` {
+		t.Fatalf("positional params encoded as %s", got)
+	}
+	if _, ok := sent[1]["params"]; ok {
+		t.Fatalf("NoParams request still has params: %s", transport.payload)
+	}
+	if got := jsonrpc.Positional(); got == nil || len(got) != 0 {
+		t.Fatalf("Positional() = %#v, want an empty array", got)
+	}
+}
+
+func TestClientNamedParams(t *testing.T) {
+	ts := newEchoServer(t)
+	c := jsonrpc.NewClient(ts.URL)
+	var out struct {
+		Address string `json:"address"`
+		Block   string `json:"block"`
+	}
+	params := jsonrpc.Named("block", "latest", "address", "0xabc")
+	data, err := json.Marshal(params)
+	if err != nil || string(data) != `{"block":"latest","address":"0xabc"}` {
+		t.Fatalf("Named encoded as %s, %v", data, err)
+	}
+	if _, err := c.Execute(&jsonrpc.GenericRequester{Method: "echo", Params: params, Result: &out}); err != nil {
+		t.Fatal(err)
+	}
+	if out.Address != "0xabc" || out.Block != "latest" {
+		t.Fatalf("unexpected result %+v", out)
+	}
+
+	for _, bad := range []jsonrpc.NamedParams{jsonrpc.Named("a"), jsonrpc.Named(1, 2)} {
+		if bad.Err() == nil {
+			t.Fatal("invalid Named arguments were accepted")
+		}
+		if _, err := c.Execute(&jsonrpc.GenericRequester{Method: "echo", Params: bad}); err == nil || !strings.Contains(err.Error(), "Named") {
+			t.Fatalf("want a descriptive error, got %v", err)
+		}
+	}
+}
+
+func TestClientProtocolVersion1(t *testing.T) {
+	var payloads []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		payloads = append(payloads, strings.TrimSpace(string(body)))
+		var req struct {
+			ID     uint64 `json:"id"`
+			Params []any  `json:"params"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("request is not a single object: %s", body)
+		}
+		result, _ := json.Marshal(req.Params)
+		w.Write([]byte(`{"id":` + strconv.FormatUint(req.ID, 10) + `,"result":` + string(result) + `,"error":null}`))
+	}))
+	defer ts.Close()
+
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithProtocolVersion("1.0"))
+	result, err := c.Execute(
+		&echoRequest{method: "getinfo"},
+		&echoRequest{method: "getblock", params: "abc"},
+		&echoRequest{method: "add", params: []int{1, 2}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payloads) != 3 || strings.Contains(payloads[0], "jsonrpc") {
+		t.Fatalf("unexpected payloads %q", payloads)
+	}
+	want := []string{`[]`, `["abc"]`, `[1,2]`}
+	for i := range want {
+		if got, _ := json.Marshal(result.At(i)); string(got) != want[i] || result.Error(i) != nil {
+			t.Errorf("result %d = %s, %v; want %s", i, got, result.Error(i), want[i])
+		}
+	}
+}
+
+func TestClientStrictResponses(t *testing.T) {
+	body := `[
+		{"id":1,"jsonrpc":"2.0","result":"ok"},
+		{"id":2,"jsonrpc":"1.0","result":"old"},
+		{"id":3,"jsonrpc":"2.0","result":null,"error":{"code":1,"message":"both"}}
+	]`
+	requests := []jsonrpc.Requester{&echoRequest{method: "a"}, &echoRequest{method: "b"}, &echoRequest{method: "c"}}
+
+	lenient, err := jsonrpc.NewClient("", jsonrpc.WithTransport(&staticTransport{body: body})).Execute(requests...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lenient.Error(1) != nil {
+		t.Fatalf("lenient client rejected entry 1: %v", lenient.Error(1))
+	}
+
+	c := jsonrpc.NewClient("", jsonrpc.WithTransport(&staticTransport{body: body}), jsonrpc.WithStrictResponses())
+	result, err := c.Execute(requests...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.At(0) != "ok" || result.Error(0) != nil {
+		t.Fatalf("valid entry rejected: %v", result.Error(0))
+	}
+	for i := 1; i < 3; i++ {
+		if err := result.Error(i); !errors.Is(err, jsonrpc.ErrInvalidResponse) {
+			t.Errorf("entry %d: got %v, want ErrInvalidResponse", i, err)
+		}
+	}
+
+	for _, body := range []string{
+		`[{"id":1,"jsonrpc":"2.0","result":"ok"},{"jsonrpc":"2.0","result":"anonymous"}]`,
+		`[{"id":1,"jsonrpc":"2.0","result":"ok"},{"id":9,"jsonrpc":"2.0","result":"stranger"}]`,
+	} {
+		c := jsonrpc.NewClient("", jsonrpc.WithTransport(&staticTransport{body: body}), jsonrpc.WithStrictResponses())
+		if _, err := c.Execute(requests[:2]...); !errors.Is(err, jsonrpc.ErrInvalidResponse) || !strings.Contains(err.Error(), "matches no request") {
+			t.Errorf("%s: got %v, want an unmatched response error", body, err)
+		}
+	}
+}
+
+func TestClientDuplicateResponseIDs(t *testing.T) {
+	body := `[{"id":1,"jsonrpc":"2.0","result":"first"},{"id":1,"jsonrpc":"2.0","result":"second"},{"id":2,"jsonrpc":"2.0","result":"b"}]`
+	requests := []jsonrpc.Requester{&echoRequest{method: "a"}, &echoRequest{method: "b"}}
+
+	var violations []error
+	c := jsonrpc.NewClient("", jsonrpc.WithTransport(&staticTransport{body: body}), jsonrpc.OnProtocolViolation(func(ctx context.Context, err error) {
+		violations = append(violations, err)
+	}))
+	result, err := c.Execute(requests...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.At(0) != "first" || result.At(1) != "b" || len(violations) != 1 {
+		t.Fatalf("results %v %v, violations %v", result.At(0), result.At(1), violations)
+	}
+
+	c = jsonrpc.NewClient("", jsonrpc.WithTransport(&staticTransport{body: body}), jsonrpc.WithStrictResponses())
+	if _, err := c.Execute(requests...); !errors.Is(err, jsonrpc.ErrInvalidResponse) || !strings.Contains(err.Error(), "id 1") {
+		t.Fatalf("got %v, want duplicate id error", err)
+	}
+}
+
+type validatedRequest struct {
+	echoRequest
+}
+
+func (r *validatedRequest) Validate() error {
+	if r.params == nil {
+		return errors.New("params are required")
+	}
+	return nil
+}
+
+func TestClientRequesterValidate(t *testing.T) {
+	transport := &staticTransport{body: `[{"id":1,"jsonrpc":"2.0","result":1}]`}
+	c := jsonrpc.NewClient("", jsonrpc.WithTransport(transport))
+	requests := []jsonrpc.Requester{&validatedRequest{echoRequest{method: "ok", params: 1}}, &validatedRequest{echoRequest{method: "transfer"}}}
+	_, err := c.Execute(requests...)
+	if err == nil || !strings.Contains(err.Error(), `"transfer" (index 1)`) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, _, _, err := c.RawExecute(requests...); err == nil {
+		t.Fatal("RawExecute skipped validation")
+	}
+	if transport.payload != nil {
+		t.Fatalf("invalid batch was sent: %s", transport.payload)
+	}
+}
+
+type idRequest struct {
+	echoRequest
+	id any
+}
+
+func (r *idRequest) ID() any {
+	return r.id
+}
+
+func TestClientRequesterWithID(t *testing.T) {
+	ts := newEchoServer(t)
+	c := jsonrpc.NewClient(ts.URL)
+	result, err := c.Execute(
+		&idRequest{echoRequest{method: "echo", params: "traced"}, "trace-<1>"},
+		&echoRequest{method: "echo", params: "counter"},
+		&idRequest{echoRequest{method: "echo", params: "numeric"}, 1000},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"traced", "counter", "numeric"} {
+		if got := result.At(i); got != want {
+			t.Errorf("result %d = %v, want %s", i, got, want)
+		}
+	}
+
+	transport := &staticTransport{body: `[]`}
+	c = jsonrpc.NewClient("", jsonrpc.WithTransport(transport))
+	for _, requests := range [][]jsonrpc.Requester{
+		{&idRequest{echoRequest{method: "a"}, "x"}, &idRequest{echoRequest{method: "b"}, "x"}},
+		{&echoRequest{method: "a"}, &idRequest{echoRequest{method: "b"}, 1}},
+		{&idRequest{echoRequest{method: "a"}, []int{1}}},
+	} {
+		if _, err := c.Execute(requests...); err == nil {
+			t.Errorf("ids of %v were accepted", requests)
+		}
+	}
+	if transport.payload != nil {
+		t.Fatalf("invalid batch was sent: %s", transport.payload)
+	}
+
+	split := jsonrpc.NewClient("", jsonrpc.WithTransport(transport), jsonrpc.WithClientMaxBatchSize(1))
+	if _, err := split.Execute(&idRequest{echoRequest{method: "a"}, 1}, &idRequest{echoRequest{method: "b"}, json.Number("1.0")}); err == nil {
+		t.Error("ids 1 and 1.0 in separate chunks were accepted")
+	}
+	if transport.payload != nil {
+		t.Fatalf("invalid batch was sent: %s", transport.payload)
+	}
+
+	transport.body = `[{"id":1.0,"jsonrpc":"2.0","result":"one"}]`
+	if result, err := c.Execute(&idRequest{echoRequest{method: "a"}, 1}); err != nil || result.At(0) != "one" {
+		t.Fatalf("response id 1.0 did not match request id 1: %v, %v", result, err)
+	}
+}
+
+type notifyRequest struct {
+	echoRequest
+}
+
+func (r *notifyRequest) Notification() bool {
+	return true
+}
+
+func TestClientNotifications(t *testing.T) {
+	var notified []any
+	var body []byte
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	s.Register("log", func(ctx context.Context, request interface{}) (interface{}, error) {
+		notified = append(notified, request)
+		return "ignored", nil
+	}, rawDecode)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		body = rec.Body.Bytes()
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	c := jsonrpc.NewClient(ts.URL)
+	result, err := c.Execute(
+		&echoRequest{method: "echo", params: "a"},
+		&notifyRequest{echoRequest{method: "log", params: "event"}},
+		&echoRequest{method: "echo", params: "b"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var responses []json.RawMessage
+	if err := json.Unmarshal(body, &responses); err != nil || len(responses) != 2 {
+		t.Fatalf("server answered %s, want two entries", body)
+	}
+	if result.At(0) != "a" || result.At(2) != "b" {
+		t.Fatalf("calls mapped to %v, %v", result.At(0), result.At(2))
+	}
+	if result.At(1) != nil || result.Error(1) != nil {
+		t.Fatalf("notification slot = %v, %v; want nil, nil", result.At(1), result.Error(1))
+	}
+	if len(notified) != 1 {
+		t.Fatalf("notification ran %d times", len(notified))
+	}
+
+	if _, err := c.Execute(&notifyRequest{echoRequest{method: "log"}}); err != nil {
+		t.Fatalf("notification-only call: %v", err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	ts := newEchoServer(t)
+	c := jsonrpc.NewClient(ts.URL)
+	type point struct {
+		X, Y int
+	}
+	result, err := c.Execute(
+		jsonrpc.Call[point]("echo", point{X: 1, Y: 2}),
+		jsonrpc.Call[int]("echo", 42),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err := jsonrpc.ResultAt[*point](result, 0); err != nil || *p != (point{1, 2}) {
+		t.Fatalf("struct result = %v, %v", p, err)
+	}
+	if n, ok := result.At(1).(*int); !ok || *n != 42 {
+		t.Fatalf("primitive result = %#v", result.At(1))
+	}
+}
+
+func TestClientDefaultDeadline(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("stall", func(ctx context.Context, request interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, rawDecode)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithDefaultDeadline(100*time.Millisecond))
+
+	start := time.Now()
+	if _, err := c.ExecuteWithContext(context.Background(), &echoRequest{method: "stall"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Fatalf("default deadline fired after %v", d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if _, err := c.ExecuteWithContext(ctx, &echoRequest{method: "stall"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d >= 100*time.Millisecond {
+		t.Fatalf("caller's shorter deadline was ignored, took %v", d)
+	}
+}
+
+func TestClientCall(t *testing.T) {
+	ts := newEchoServer(t)
+	c := jsonrpc.NewClient(ts.URL)
+	var out map[string]int
+	if err := c.Call(context.Background(), "echo", map[string]int{"a": 1}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out["a"] != 1 {
+		t.Fatalf("got %v", out)
+	}
+	if err := c.Call(context.Background(), "echo", 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	err := c.Call(context.Background(), "missing", nil, &out)
+	if rpcErr, ok := err.(*jsonrpc.Error); !ok || rpcErr.Code() != -32601 {
+		t.Fatalf("got %v, want *jsonrpc.Error -32601", err)
+	}
+}
+
+func TestClientErrorsIs(t *testing.T) {
+	ts, _ := newCountingServer(t)
+	result, err := jsonrpc.NewClient(ts.URL).Execute(&echoRequest{method: "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpcErr := result.Error(0)
+	if !errors.Is(rpcErr, jsonrpc.ErrMethodNotFound) || errors.Is(rpcErr, jsonrpc.ErrInvalidParams) {
+		t.Fatalf("%v does not match by code", rpcErr)
+	}
+	if !errors.Is(rpcErr, jsonrpc.NewError(-32601, "", nil)) || errors.Is(rpcErr, jsonrpc.NewError(-32004, "", nil)) {
+		t.Fatalf("%v does not match *Error by code", rpcErr)
+	}
+
+	_, err = jsonrpc.NewClient("http://127.0.0.1:1").Execute(&echoRequest{method: "echo"})
+	var transportErr *jsonrpc.TransportError
+	var rpcErrTarget *jsonrpc.Error
+	if !errors.As(err, &transportErr) || errors.As(err, &rpcErrTarget) {
+		t.Fatalf("got %T %v, want *TransportError", err, err)
+	}
+}
+
+func TestClientMiddleware(t *testing.T) {
+	ts, calls := newCountingServer(t)
+	var order []string
+	trace := func(name string) jsonrpc.ClientMiddleware {
+		return func(next jsonrpc.ExecuteFunc) jsonrpc.ExecuteFunc {
+			return func(ctx context.Context, requests []jsonrpc.Requester) (*jsonrpc.BatchResult, error) {
+				order = append(order, name)
+				return next(ctx, requests)
+			}
+		}
+	}
+	shortCircuit := func(next jsonrpc.ExecuteFunc) jsonrpc.ExecuteFunc {
+		return func(ctx context.Context, requests []jsonrpc.Requester) (*jsonrpc.BatchResult, error) {
+			if len(requests) == 0 {
+				return nil, errors.New("empty")
+			}
+			return next(ctx, requests)
+		}
+	}
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithMiddleware(trace("a"), trace("b"), shortCircuit))
+	if result, err := c.Execute(&echoRequest{method: "echo", params: "x"}); err != nil || result.At(0) != "x" {
+		t.Fatalf("got %v, %v", result, err)
+	}
+	if _, err := c.Execute(); err == nil || *calls != 1 {
+		t.Fatalf("middleware did not short-circuit: %v", err)
+	}
+	if strings.Join(order, "") != "abab" {
+		t.Fatalf("order %v", order)
+	}
+}
+
+func TestClientReauth(t *testing.T) {
+	s := jsonrpc.NewServer(jsonrpc.Before(jsonrpc.AuthBefore(func(ctx context.Context, token string) (context.Context, error) {
+		if token != "fresh" {
+			return ctx, errors.New("expired")
+		}
+		return ctx, nil
+	})))
+	s.Register("echo", echoEndpoint, rawDecode)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	var refreshes atomic.Int32
+	refresh := func(ctx context.Context) (string, error) {
+		refreshes.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return "fresh", nil
+	}
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithReauth(refresh, nil))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := c.Execute(&echoRequest{method: "echo", params: "x"})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if err := result.Error(0); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := refreshes.Load(); n != 1 {
+		t.Fatalf("token refreshed %d times, want 1", n)
+	}
+
+	release := make(chan struct{})
+	c = jsonrpc.NewClient(ts.URL, jsonrpc.WithReauth(func(ctx context.Context) (string, error) {
+		<-release
+		return "fresh", nil
+	}, nil))
+	go func() { _, _ = c.Execute(&echoRequest{method: "echo", params: "x"}) }()
+	time.Sleep(20 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.ExecuteWithContext(ctx, &echoRequest{method: "echo", params: "x"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("waiting for a refresh: got %v, want DeadlineExceeded", err)
+	}
+	close(release)
+}
@@ -0,0 +1,88 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrClientClosed = errors.New("jsonrpc: client closed")
+
+// WithCloseGrace lets calls that are in flight when Close is called run
+// for up to d before they are canceled. Without it Close cancels them
+// immediately.
+func WithCloseGrace(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.closeGrace = d
+	}
+}
+
+// WithDefaultDeadline bounds every call whose context has no deadline of
+// its own to d. A caller's deadline is left alone, so the shorter one
+// always wins.
+func WithDefaultDeadline(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.defaultDeadline = d
+	}
+}
+
+func (c *Client) begin(ctx context.Context) (context.Context, func(), error) {
+	if c.err != nil {
+		return nil, nil, c.err
+	}
+	c.closeMu.RLock()
+	if c.closed {
+		c.closeMu.RUnlock()
+		return nil, nil, ErrClientClosed
+	}
+	c.inflight.Add(1)
+	c.closeMu.RUnlock()
+	var cancel context.CancelFunc
+	if _, ok := ctx.Deadline(); !ok && c.opts.defaultDeadline > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.opts.defaultDeadline)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	stop := context.AfterFunc(c.closeCtx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		c.inflight.Done()
+	}, nil
+}
+
+// Close makes later calls fail with ErrClientClosed, cancels in-flight
+// calls once the close grace period has elapsed and shuts down transports
+// created by the client itself, including idle connections of an HTTP
+// transport it cloned for WithTLSConfig or a unix:// target. Transports
+// passed in with WithTransport or WithHTTPClient are left to the caller.
+// Calling Close more than once is safe.
+func (c *Client) Close() error {
+	c.closeMu.Lock()
+	if c.closed {
+		c.closeMu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.closeMu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		c.inflight.Wait()
+		close(drained)
+	}()
+	if c.opts.closeGrace > 0 {
+		select {
+		case <-drained:
+		case <-time.After(c.opts.closeGrace):
+		}
+	}
+	c.cancelClose()
+	<-drained
+
+	if closer, ok := c.opts.transport.(io.Closer); ok && c.ownsTransport {
+		return closer.Close()
+	}
+	return nil
+}
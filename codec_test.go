@@ -0,0 +1,94 @@
+package jsonrpc_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/555f/jsonrpc"
+)
+
+type countingCodec struct {
+	mu                 sync.Mutex
+	marshal, unmarshal int
+	encoders, decoders int
+	encoded            []string
+}
+
+func (c *countingCodec) Marshal(v any) ([]byte, error) {
+	c.mu.Lock()
+	c.marshal++
+	c.mu.Unlock()
+	return json.Marshal(v)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v any) error {
+	c.mu.Lock()
+	c.unmarshal++
+	c.mu.Unlock()
+	return json.Unmarshal(data, v)
+}
+
+func (c *countingCodec) NewEncoder(w io.Writer) jsonrpc.Encoder {
+	c.mu.Lock()
+	c.encoders++
+	c.mu.Unlock()
+	return &recordingEncoder{c: c, w: w}
+}
+
+func (c *countingCodec) NewDecoder(r io.Reader) jsonrpc.Decoder {
+	c.mu.Lock()
+	c.decoders++
+	c.mu.Unlock()
+	return json.NewDecoder(r)
+}
+
+type recordingEncoder struct {
+	c *countingCodec
+	w io.Writer
+}
+
+func (e *recordingEncoder) Encode(v any) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		return err
+	}
+	e.c.mu.Lock()
+	e.c.encoded = append(e.c.encoded, buf.String())
+	e.c.mu.Unlock()
+	_, err := e.w.Write(buf.Bytes())
+	return err
+}
+
+func TestCodec(t *testing.T) {
+	serverCodec, clientCodec := &countingCodec{}, &countingCodec{}
+	s := jsonrpc.NewServer(jsonrpc.WithCodec(serverCodec))
+	s.Register("echo", echoEndpoint, rawDecode)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithClientCodec(clientCodec))
+	result, err := c.Execute(&echoRequest{method: "echo", params: "codec"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.At(0); got != "codec" {
+		t.Fatalf("got %v, want codec", got)
+	}
+	checkCodec(t, "server", serverCodec, 1, 1, 1, 1, `[{"id":1,"jsonrpc":"2.0","result":"codec"}]`)
+	checkCodec(t, "client", clientCodec, 0, 1, 1, 0, `[{"id":1,"jsonrpc":"2.0","method":"echo","params":"codec"}]`)
+}
+
+func checkCodec(t *testing.T, name string, c *countingCodec, marshal, unmarshal, encoders, decoders int, encoded string) {
+	t.Helper()
+	if c.marshal != marshal || c.unmarshal != unmarshal || c.encoders != encoders || c.decoders != decoders {
+		t.Errorf("%s codec: marshal=%d unmarshal=%d encoders=%d decoders=%d, want %d %d %d %d",
+			name, c.marshal, c.unmarshal, c.encoders, c.decoders, marshal, unmarshal, encoders, decoders)
+	}
+	if len(c.encoded) != 1 || c.encoded[0] != encoded+"\n" {
+		t.Errorf("%s codec encoded %q, want %q", name, c.encoded, encoded)
+	}
+}
@@ -0,0 +1,58 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// ListMethodsMethod is the introspection method registered by
+// WithIntrospection.
+const ListMethodsMethod = "rpc.listMethods"
+
+// MethodDescription is one entry of the ListMethodsMethod result.
+type MethodDescription struct {
+	Name         string          `json:"name"`
+	Description  string          `json:"description,omitempty"`
+	ParamSchema  json.RawMessage `json:"params,omitempty"`
+	ResultSchema json.RawMessage `json:"result,omitempty"`
+}
+
+// Describe attaches a description and optional JSON Schemas for params and
+// result to the method, reported by ListMethodsMethod.
+func (m *ServerMethod) Describe(description string, paramSchema, resultSchema json.RawMessage) *ServerMethod {
+	m.description = description
+	m.paramSchema = paramSchema
+	m.resultSchema = resultSchema
+	return m
+}
+
+// WithIntrospection registers ListMethodsMethod, which returns a
+// MethodDescription for every registered method, sorted by name.
+func WithIntrospection() Option {
+	return func(o *Options) {
+		o.introspection = true
+	}
+}
+
+func (s *Server) listMethods(ctx context.Context, request any) (any, error) {
+	methods := make([]MethodDescription, 0, len(s.methods))
+	for name, m := range s.methods {
+		if name == ListMethodsMethod {
+			continue
+		}
+		methods = append(methods, MethodDescription{
+			Name:         name,
+			Description:  m.description,
+			ParamSchema:  m.paramSchema,
+			ResultSchema: m.resultSchema,
+		})
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+	return methods, nil
+}
+
+func noParamsDecode(ctx context.Context, r *http.Request, params json.RawMessage) (any, error) {
+	return nil, nil
+}
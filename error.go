@@ -1,9 +1,56 @@
 package jsonrpc
 
-type Error struct {
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Errors returned by decoders and endpoints that wrap one of these are
+// answered with the matching standard code and the error's message. On
+// the client, errors.Is reports whether an *Error carries that code.
+var (
+	ErrParseError     = errors.New("parse error")
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrMethodNotFound = errors.New("method not found")
+	ErrInvalidParams  = errors.New("invalid params")
+	ErrInternal       = errors.New("internal error")
+)
+
+var sentinelCodes = []struct {
+	err  error
 	code int
+}{
+	{ErrParseError, jsonRPCParseError},
+	{ErrInvalidRequest, jsonRPCInvalidRequestError},
+	{ErrMethodNotFound, jsonRPCMethodNotFoundError},
+	{ErrInvalidParams, jsonRPCInvalidParamsError},
+	{ErrInternal, jsonRPCInternalError},
+}
+
+func IsInvalidParams(err error) bool {
+	return errors.Is(err, ErrInvalidParams)
+}
+
+func sentinelCode(err error) (int, bool) {
+	for _, s := range sentinelCodes {
+		if errors.Is(err, s.err) {
+			return s.code, true
+		}
+	}
+	return 0, false
+}
+
+type Error struct {
+	code    int
 	message string
-	data    any   
+	data    any
+	rawData json.RawMessage
+	err     error
+}
+
+func NewError(code int, message string, data any) *Error {
+	return &Error{code: code, message: message, data: data}
 }
 
 func (e *Error) Code() int {
@@ -16,4 +63,79 @@ func (e *Error) Data() any {
 
 func (e *Error) Error() string {
 	return e.message
-}
\ No newline at end of file
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Is matches the sentinel of e's standard code, and any *Error with the
+// same code, so errors.Is(err, NewError(-32004, "", nil)) checks for an
+// application code regardless of message.
+func (e *Error) Is(target error) bool {
+	if t, ok := target.(*Error); ok {
+		return t.code == e.code
+	}
+	for _, s := range sentinelCodes {
+		if target == s.err {
+			return s.code == e.code
+		}
+	}
+	return false
+}
+
+// ErrorDataAs finds the first *Error in err's chain and decodes its data
+// into target. Errors received by the client are decoded from the raw
+// bytes sent by the server. It returns an error if there is no *Error in
+// the chain and leaves target untouched when the error has no data.
+func ErrorDataAs(err error, target any) error {
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) {
+		return errors.New("jsonrpc: no *Error in chain")
+	}
+	raw := rpcErr.rawData
+	if len(raw) == 0 {
+		if rpcErr.data == nil {
+			return nil
+		}
+		var err error
+		if raw, err = json.Marshal(rpcErr.data); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(raw, target)
+}
+
+// ResultError reports a MakeResult failure together with the request it
+// belongs to and the raw result that could not be decoded.
+type ResultError struct {
+	Method string
+	Index  int
+	Raw    json.RawMessage
+	Err    error
+}
+
+func (e *ResultError) Error() string {
+	return fmt.Sprintf("jsonrpc: decoding result for method %q (index %d): %v", e.Method, e.Index, e.Err)
+}
+
+func (e *ResultError) Unwrap() error {
+	return e.Err
+}
+
+// TransportError reports that a call failed without a JSON-RPC response,
+// because the request could not be sent or the server answered with an
+// HTTP error status, in which case StatusCode is set. Context cancellation
+// is returned as is.
+type TransportError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *TransportError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
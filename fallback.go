@@ -0,0 +1,19 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+)
+
+// ExecuteWithFallback sends requests with primary and, if that fails with
+// a *TransportError, sends them again with fallback. JSON-RPC errors in
+// the result do not trigger the fallback. Ids are assigned by the client
+// that sends a call, so the retry never reuses the ids of the first try.
+func ExecuteWithFallback(ctx context.Context, primary, fallback *Client, requests ...Requester) (*BatchResult, error) {
+	result, err := primary.ExecuteWithContext(ctx, requests...)
+	var transportErr *TransportError
+	if err == nil || !errors.As(err, &transportErr) || ctx.Err() != nil {
+		return result, err
+	}
+	return fallback.ExecuteWithContext(ctx, requests...)
+}
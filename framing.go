@@ -0,0 +1,72 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// errBadFrame reports a frame that was read but could not be used. The
+// reader is positioned at the next frame, so reading may continue.
+var errBadFrame = errors.New("jsonrpc: malformed frame")
+
+// defaultMaxMessageBytes caps a single message read from a stream or
+// connection when no smaller limit is configured.
+const defaultMaxMessageBytes = 32 << 20
+
+type frameReader struct {
+	r     *bufio.Reader
+	limit int64
+	skip  int64 // body bytes of a rejected frame still to discard
+}
+
+// newFrameReader reads frames of at most limit bytes, or
+// defaultMaxMessageBytes if limit is not positive.
+func newFrameReader(r io.Reader, limit int64) *frameReader {
+	if limit <= 0 {
+		limit = defaultMaxMessageBytes
+	}
+	return &frameReader{r: bufio.NewReader(r), limit: limit}
+}
+
+func (f *frameReader) ReadMessage() ([]byte, error) {
+	if n := f.skip; n > 0 {
+		f.skip = 0
+		if _, err := io.CopyN(io.Discard, f.r, n); err != nil {
+			return nil, err
+		}
+	}
+	header, err := textproto.NewReader(f.r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	value := header.Get("Content-Length")
+	if value == "" {
+		return nil, fmt.Errorf("%w: missing Content-Length", errBadFrame)
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("%w: invalid Content-Length %q", errBadFrame, value)
+	}
+	if n > f.limit {
+		f.skip = n
+		return nil, fmt.Errorf("%w: Content-Length %d exceeds %d bytes", errBadFrame, n, f.limit)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(f.r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func writeFrame(w io.Writer, data []byte) error {
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
@@ -1,3 +1,10 @@
 module github.com/555f/jsonrpc
 
-go 1.20
+go 1.21
+
+require (
+	github.com/gorilla/websocket v1.5.1
+	golang.org/x/time v0.5.0
+)
+
+require golang.org/x/net v0.17.0 // indirect
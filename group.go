@@ -0,0 +1,36 @@
+package jsonrpc
+
+// Registrar is implemented by Server and ServerGroup, so helpers such as
+// RegisterFunc work with both.
+type Registrar interface {
+	Register(method string, endpoint Endpoint, reqDecode ReqDecode, opts ...Option) *ServerMethod
+	serverCodec() Codec
+}
+
+// ServerGroup registers methods under a shared "prefix." namespace with
+// shared options.
+type ServerGroup struct {
+	server *Server
+	prefix string
+	opts   []Option
+}
+
+// Group returns a ServerGroup whose methods are named prefix + "." + name.
+// Its options are applied after the server's and before the per-method
+// ones, so group Before, After and EndpointMiddleware stack between the two.
+func (s *Server) Group(prefix string, opts ...Option) *ServerGroup {
+	return &ServerGroup{server: s, prefix: prefix, opts: opts}
+}
+
+func (g *ServerGroup) Register(method string, endpoint Endpoint, reqDecode ReqDecode, opts ...Option) *ServerMethod {
+	merged := append(append([]Option(nil), g.opts...), opts...)
+	return g.server.Register(g.prefix+"."+method, endpoint, reqDecode, merged...)
+}
+
+func (g *ServerGroup) serverCodec() Codec {
+	return g.server.opts.codec
+}
+
+func (s *Server) serverCodec() Codec {
+	return s.opts.codec
+}
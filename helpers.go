@@ -1,15 +1,12 @@
 package jsonrpc
 
-func middlewareChain(middlewares []EndpointMiddlewareFunc) EndpointMiddlewareFunc {
-	return func(next Endpoint) Endpoint {
-		if len(middlewares) == 0 {
-			return next
+// middlewareChain wraps next so that middlewares[0] is the outermost:
+// [A, B, C] produces A(B(C(next))), and A sees a call first.
+func middlewareChain[T any](middlewares []func(T) T) func(T) T {
+	return func(next T) T {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			next = middlewares[i](next)
 		}
-		outer := middlewares[0]
-		others := middlewares[1:]
-		for i := len(others) - 1; i >= 0; i-- {
-			next = others[i](next)
-		}
-		return outer(next)
+		return next
 	}
 }
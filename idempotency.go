@@ -0,0 +1,237 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// ErrIdempotencyKeyReused is the error of a request whose Idempotency-Key
+// was already used with a different body.
+var ErrIdempotencyKeyReused = errors.New("jsonrpc: idempotency key reused with a different request")
+
+var errMultipleIdempotencyKeys = errors.New("jsonrpc: more than one idempotency key in a single call")
+
+// RequesterWithIdempotencyKey is implemented by requests that must carry an
+// Idempotency-Key header. Execute sends every keyed request in an HTTP call
+// of its own so each key maps to exactly one call; RawExecute, which always
+// makes a single call, fails if given more than one keyed request. The
+// header is set once per call and so is reused by retries done in a
+// round-trip middleware.
+type RequesterWithIdempotencyKey interface {
+	IdempotencyKey() string
+}
+
+func idempotencyKey(r Requester) string {
+	if v, ok := r.(RequesterWithIdempotencyKey); ok {
+		return v.IdempotencyKey()
+	}
+	return ""
+}
+
+func setIdempotencyKey(key string) ClientBeforeFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		r.Header.Set(IdempotencyKeyHeader, key)
+		return ctx
+	}
+}
+
+// WithIdempotencyKey sends a freshly generated UUID as the Idempotency-Key
+// header of every HTTP call, unless a request supplies its own key. Calls
+// split by WithClientMaxBatchSize each get their own key, while retries of
+// one call made by a round-trip middleware share it.
+func WithIdempotencyKey() ClientOption {
+	return func(o *clientOptions) {
+		o.idempotencyKeys = true
+	}
+}
+
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// IdempotencyCache stores encoded responses by idempotency key. A ttl of 0
+// passed to Set means the cache's own default.
+type IdempotencyCache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, resp []byte, ttl time.Duration)
+}
+
+// WithIdempotencyCache makes the server answer a request carrying an
+// Idempotency-Key header it has already seen with the cached response
+// body, without running any endpoint. Entries are keyed by the header
+// together with the caller's Authorization header, and a replay still has
+// to pass the rate limit, auth middleware and Before funcs. Reusing a key
+// with a different body fails with ErrIdempotencyKeyReused, and requests
+// arriving while the first one with their key runs wait for its response.
+// Only complete 200 responses are cached; streamed results and 204
+// responses are not.
+func WithIdempotencyCache(cache IdempotencyCache) Option {
+	return func(o *Options) {
+		o.idempotencyCache = cache
+	}
+}
+
+type idempotencyEntry struct {
+	resp    []byte
+	expires time.Time
+}
+
+type inMemoryIdempotencyCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]idempotencyEntry
+}
+
+func NewInMemoryIdempotencyCache(ttl time.Duration) IdempotencyCache {
+	return &inMemoryIdempotencyCache{ttl: ttl, entries: make(map[string]idempotencyEntry)}
+}
+
+func (c *inMemoryIdempotencyCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.resp, true
+}
+
+func (c *inMemoryIdempotencyCache) Set(key string, resp []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = idempotencyEntry{resp: resp, expires: now.Add(ttl)}
+}
+
+// idempotentCall is the request that runs for an idempotency key while
+// later ones with the same key wait for it.
+type idempotentCall struct {
+	key      string
+	bodyHash []byte
+	release  func()
+}
+
+// serveIdempotent answers a request from the idempotency cache if it can.
+// Otherwise it returns the call under which the response is to be cached,
+// and whose release must be called once it is.
+func (s *Server) serveIdempotent(ctx context.Context, w http.ResponseWriter, r *http.Request, key string, raw []byte, requestData jsonRPCRequestData) (*idempotentCall, bool) {
+	principal := sha256.Sum256([]byte(r.Header.Get("Authorization")))
+	bodyHash := sha256.Sum256(raw)
+	call := &idempotentCall{key: fmt.Sprintf("%s\x00%x", key, principal), bodyHash: bodyHash[:]}
+	for {
+		if cached, ok := s.opts.idempotencyCache.Get(call.key); ok && len(cached) >= sha256.Size {
+			if !bytes.Equal(cached[:sha256.Size], call.bodyHash) {
+				s.writeErrorResponses(w, http.StatusUnprocessableEntity, requestData, &Error{code: jsonRPCInvalidRequestError, message: ErrIdempotencyKeyReused.Error(), err: ErrIdempotencyKeyReused})
+				return nil, true
+			}
+			if err := s.admit(ctx, w, r, requestData.requests); err != nil {
+				s.writeErrorResponses(w, http.StatusOK, requestData, err)
+				return nil, true
+			}
+			s.setHeaders(w)
+			_, _ = w.Write(cached[sha256.Size:])
+			return nil, true
+		}
+		s.idempotencyMu.Lock()
+		if running, ok := s.idempotencyCalls[call.key]; ok {
+			s.idempotencyMu.Unlock()
+			select {
+			case <-running:
+				continue
+			case <-ctx.Done():
+				return nil, true
+			}
+		}
+		done := make(chan struct{})
+		if s.idempotencyCalls == nil {
+			s.idempotencyCalls = make(map[string]chan struct{})
+		}
+		s.idempotencyCalls[call.key] = done
+		s.idempotencyMu.Unlock()
+		call.release = func() {
+			s.idempotencyMu.Lock()
+			delete(s.idempotencyCalls, call.key)
+			s.idempotencyMu.Unlock()
+			close(done)
+		}
+		return call, false
+	}
+}
+
+func (c *idempotentCall) store(cache IdempotencyCache, resp []byte) {
+	cache.Set(c.key, append(append([]byte(nil), c.bodyHash...), resp...), 0)
+}
+
+// admit runs the checks a fresh call of each request would have to pass
+// before its endpoint: the rate limit, auth middleware and Before funcs.
+func (s *Server) admit(ctx context.Context, w http.ResponseWriter, r *http.Request, requests []Request) error {
+	for _, req := range requests {
+		if s.opts.limiter != nil && !s.opts.limiter.Allow() {
+			return NewError(jsonRPCServerError, "rate limit exceeded", nil)
+		}
+		method, ok := s.methods[req.Method]
+		if !ok {
+			continue
+		}
+		req := req
+		methodCtx := context.WithValue(ctx, responseWriterKey{}, w)
+		methodCtx = context.WithValue(methodCtx, requestKey{}, &req)
+		if err := method.opts.authorize(methodCtx, r); err != nil {
+			return err
+		}
+		for _, before := range method.opts.before {
+			beforeCtx, err := before(methodCtx, r)
+			if err != nil {
+				return err
+			}
+			methodCtx, ctx = beforeCtx, beforeCtx
+		}
+	}
+	return nil
+}
+
+// writeErrorResponses answers every call of requestData with err.
+func (s *Server) writeErrorResponses(w http.ResponseWriter, status int, requestData jsonRPCRequestData, err error) {
+	var responses []jsonRPCResponse
+	for _, req := range requestData.requests {
+		if !req.ID.notification() {
+			responses = append(responses, s.makeErrorResponseFromError(req.ID, err))
+		}
+	}
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	var data any = responses
+	if !requestData.isBatch {
+		data = responses[0]
+	}
+	s.setHeaders(w)
+	w.WriteHeader(status)
+	_ = s.opts.codec.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,76 @@
+package jsonrpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// RequesterWithID is implemented by requests that bring their own id,
+// which must encode to a JSON string or number. Ids have to be unique
+// within an Execute, even across the calls it is split into, and within
+// each call also against the client's numeric counter, or nothing is sent.
+// Numbers are compared by value. Custom ids are not part of the id map
+// returned by RawExecute.
+type RequesterWithID interface {
+	ID() any
+}
+
+// idKey normalizes an encoded id so a request and its response match even
+// if the string escaping or the spelling of a number differs, as in 1 and
+// 1.0.
+func idKey(raw []byte) string {
+	if len(raw) > 0 && raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return "s:" + s
+		}
+	}
+	if n, ok := new(big.Rat).SetString(string(raw)); ok {
+		return "n:" + n.RatString()
+	}
+	return "n:" + string(raw)
+}
+
+// checkIDs fails if two requests of a logical batch bring the same id,
+// before it is split into calls by size, idempotency key or target.
+func checkIDs(requests []Requester) error {
+	var seen map[string]int
+	for i, request := range requests {
+		v, ok := request.(RequesterWithID)
+		if !ok || isNotification(request) {
+			continue
+		}
+		raw, err := json.Marshal(v.ID())
+		if err != nil {
+			continue
+		}
+		if seen == nil {
+			seen = make(map[string]int)
+		}
+		key := idKey(raw)
+		if j, ok := seen[key]; ok {
+			return fmt.Errorf("jsonrpc: request %d reuses the id of request %d", i, j)
+		}
+		seen[key] = i
+	}
+	return nil
+}
+
+func (c *Client) requestID(request Requester) (id any, key string, err error) {
+	v, ok := request.(RequesterWithID)
+	if !ok {
+		n := c.autoIncrementID()
+		return n, "n:" + strconv.FormatUint(n, 10), nil
+	}
+	id = v.ID()
+	raw, err := json.Marshal(id)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(raw) == 0 || !(raw[0] == '"' || raw[0] == '-' || raw[0] >= '0' && raw[0] <= '9') {
+		return nil, "", fmt.Errorf("jsonrpc: id %s must be a string or number", raw)
+	}
+	return id, idKey(raw), nil
+}
@@ -0,0 +1,142 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"io"
+)
+
+// WithJSON5Support lets the server accept request bodies with // and /* */
+// comments and trailing commas. The body is cleaned up while it is read
+// and then decoded as usual, so anything else that is not JSON still
+// fails with a Parse error.
+func WithJSON5Support() Option {
+	return func(o *Options) {
+		o.json5 = true
+	}
+}
+
+// json5Reader strips comments and trailing commas from a JSON stream. A
+// comma is held back, with the whitespace after it, until the next token
+// shows whether it closes an array or object.
+type json5Reader struct {
+	r        *bufio.Reader
+	out      []byte
+	pending  []byte
+	comma    bool
+	inString bool
+	escape   bool
+	err      error
+}
+
+func newJSON5Reader(r io.Reader) *json5Reader {
+	return &json5Reader{r: bufio.NewReader(r)}
+}
+
+func (j *json5Reader) Read(p []byte) (int, error) {
+	for len(j.out) < len(p) && j.err == nil {
+		j.step()
+	}
+	n := copy(p, j.out)
+	j.out = j.out[n:]
+	if n == 0 && j.err != nil {
+		return 0, j.err
+	}
+	return n, nil
+}
+
+func (j *json5Reader) flush() {
+	if j.comma {
+		j.out = append(j.out, ',')
+		j.comma = false
+	}
+	j.out = append(j.out, j.pending...)
+	j.pending = j.pending[:0]
+}
+
+func (j *json5Reader) step() {
+	b, err := j.r.ReadByte()
+	if err != nil {
+		j.flush()
+		j.err = err
+		return
+	}
+	if j.inString {
+		j.out = append(j.out, b)
+		switch {
+		case j.escape:
+			j.escape = false
+		case b == '\\':
+			j.escape = true
+		case b == '"':
+			j.inString = false
+		}
+		return
+	}
+	switch b {
+	case '"':
+		j.flush()
+		j.out = append(j.out, b)
+		j.inString = true
+	case '/':
+		next, err := j.r.ReadByte()
+		switch {
+		case err == nil && next == '/':
+			j.skipLine()
+		case err == nil && next == '*':
+			j.skipBlock()
+		default:
+			if err == nil {
+				_ = j.r.UnreadByte()
+			}
+			j.flush()
+			j.out = append(j.out, b)
+		}
+	case ' ', '\t', '\n', '\r':
+		j.space(b)
+	case ',':
+		j.flush()
+		j.comma = true
+	case ']', '}':
+		j.comma = false
+		j.flush()
+		j.out = append(j.out, b)
+	default:
+		j.flush()
+		j.out = append(j.out, b)
+	}
+}
+
+func (j *json5Reader) space(b byte) {
+	if j.comma {
+		j.pending = append(j.pending, b)
+	} else {
+		j.out = append(j.out, b)
+	}
+}
+
+func (j *json5Reader) skipLine() {
+	for {
+		b, err := j.r.ReadByte()
+		if err != nil || b == '\n' {
+			if err == nil {
+				_ = j.r.UnreadByte()
+			}
+			return
+		}
+	}
+}
+
+func (j *json5Reader) skipBlock() {
+	var prev byte
+	for {
+		b, err := j.r.ReadByte()
+		if err != nil {
+			return
+		}
+		if prev == '*' && b == '/' {
+			j.space(' ')
+			return
+		}
+		prev = b
+	}
+}
@@ -0,0 +1,24 @@
+module github.com/555f/jsonrpc/jsonrpcotel
+
+go 1.21
+
+require (
+	github.com/555f/jsonrpc v0.0.0-20261014090906-29c3d7f8ebf1
+	go.opentelemetry.io/otel v1.19.0
+	go.opentelemetry.io/otel/sdk v1.19.0
+	go.opentelemetry.io/otel/trace v1.19.0
+)
+
+require (
+	github.com/go-logr/logr v1.2.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/gorilla/websocket v1.5.1 // indirect
+	go.opentelemetry.io/otel/metric v1.19.0 // indirect
+	golang.org/x/net v0.17.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/time v0.5.0 // indirect
+)
+
+// Builds inside this repository use the core module next to it; users of
+// the module get the version required above.
+replace github.com/555f/jsonrpc => ../
@@ -0,0 +1,115 @@
+// Package jsonrpcotel adds OpenTelemetry client spans to jsonrpc.Client.
+// It is a module of its own so the core jsonrpc module does not depend on
+// OpenTelemetry.
+package jsonrpcotel
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/555f/jsonrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/555f/jsonrpc/jsonrpcotel"
+
+type config struct {
+	provider    trace.TracerProvider
+	propagators propagation.TextMapPropagator
+}
+
+type Option func(*config)
+
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.provider = provider
+	}
+}
+
+func WithPropagators(propagators propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagators = propagators
+	}
+}
+
+type spanKey struct{}
+
+type spanState struct {
+	span    trace.Span
+	methods []string
+}
+
+// ClientOptions returns the options that trace a Client: a round trip
+// middleware that holds one span per call, a BeforeRequest hook that
+// starts it and injects the propagation headers into every request of the
+// call, redirects included, and a metrics observer that annotates and ends
+// it once every batch entry has been observed. Spans are only created for
+// HTTP transports, since the hooks run against the outgoing *http.Request.
+func ClientOptions(opts ...Option) []jsonrpc.ClientOption {
+	c := &config{
+		provider:    otel.GetTracerProvider(),
+		propagators: otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	tracer := c.provider.Tracer(instrumentationName)
+	roundTrip := func(next jsonrpc.RoundTripper) jsonrpc.RoundTripper {
+		return func(ctx context.Context, payload []byte) ([]byte, error) {
+			return next(context.WithValue(ctx, spanKey{}, &spanState{}), payload)
+		}
+	}
+	before := func(ctx context.Context, r *http.Request) context.Context {
+		state, ok := ctx.Value(spanKey{}).(*spanState)
+		if !ok {
+			return ctx
+		}
+		if state.span == nil {
+			_, state.span = tracer.Start(ctx, "jsonrpc", trace.WithSpanKind(trace.SpanKindClient))
+		}
+		ctx = trace.ContextWithSpan(ctx, state.span)
+		c.propagators.Inject(ctx, propagation.HeaderCarrier(r.Header))
+		return ctx
+	}
+	observe := func(ctx context.Context, o jsonrpc.ClientObservation) {
+		state, ok := ctx.Value(spanKey{}).(*spanState)
+		if !ok || state.span == nil {
+			return
+		}
+		state.methods = append(state.methods, o.Method)
+		if o.ErrorClass == jsonrpc.ErrorClassRPC {
+			state.span.AddEvent("rpc.jsonrpc.error", trace.WithAttributes(
+				attribute.Int("rpc.jsonrpc.index", o.Index),
+				attribute.Int("rpc.jsonrpc.error_code", o.ErrorCode),
+				attribute.String("rpc.jsonrpc.error_message", o.Err.Error()),
+			))
+		} else if o.Err != nil && o.Index == 0 {
+			state.span.RecordError(o.Err)
+			state.span.SetStatus(codes.Error, o.ErrorClass)
+		}
+		if o.Index != o.BatchSize-1 {
+			return
+		}
+		method := strings.Join(state.methods, ",")
+		state.span.SetName(method)
+		state.span.SetAttributes(
+			attribute.String("rpc.system", "jsonrpc"),
+			attribute.String("rpc.method", method),
+			attribute.Int("rpc.jsonrpc.batch_size", o.BatchSize),
+		)
+		if o.HTTPStatus != 0 {
+			state.span.SetAttributes(attribute.Int("http.status_code", o.HTTPStatus))
+		}
+		state.span.End()
+	}
+	return []jsonrpc.ClientOption{
+		jsonrpc.WithRoundTripMiddleware(roundTrip),
+		jsonrpc.BeforeRequest(before),
+		jsonrpc.WithClientMetrics(observe),
+	}
+}
@@ -0,0 +1,112 @@
+package jsonrpcotel_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/555f/jsonrpc"
+	"github.com/555f/jsonrpc/jsonrpcotel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type request struct {
+	method string
+}
+
+func (r request) MakeRequest() (string, any) {
+	return r.method, nil
+}
+
+func (r request) MakeResult(data []byte) (any, error) {
+	var v any
+	return v, json.Unmarshal(data, &v)
+}
+
+func TestClientOptions(t *testing.T) {
+	var traceparent string
+	s := jsonrpc.NewServer(jsonrpc.Before(func(ctx context.Context, r *http.Request) (context.Context, error) {
+		traceparent = r.Header.Get("traceparent")
+		return ctx, nil
+	}))
+	s.Register("ping", func(ctx context.Context, request interface{}) (interface{}, error) {
+		return "pong", nil
+	}, func(ctx context.Context, r *http.Request, params json.RawMessage) (any, error) {
+		return nil, nil
+	})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	opts := jsonrpcotel.ClientOptions(
+		jsonrpcotel.WithTracerProvider(provider),
+		jsonrpcotel.WithPropagators(propagation.TraceContext{}),
+	)
+	c := jsonrpc.NewClient(ts.URL, opts...)
+	if _, err := c.Execute(request{method: "ping"}, request{method: "missing"}); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "ping,missing" {
+		t.Fatalf("got span name %q", span.Name())
+	}
+	attrs := map[string]string{}
+	for _, kv := range span.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["rpc.system"] != "jsonrpc" || attrs["rpc.jsonrpc.batch_size"] != "2" {
+		t.Fatalf("unexpected attributes %v", attrs)
+	}
+	if events := span.Events(); len(events) != 1 || events[0].Name != "rpc.jsonrpc.error" {
+		t.Fatalf("unexpected events %v", events)
+	}
+	if traceparent == "" {
+		t.Fatal("traceparent header was not injected")
+	}
+}
+
+func TestClientOptionsEndsEverySpan(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("ping", func(ctx context.Context, request interface{}) (interface{}, error) {
+		return "pong", nil
+	}, func(ctx context.Context, r *http.Request, params json.RawMessage) (any, error) {
+		return nil, nil
+	})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hop" {
+			http.Redirect(w, r, ts.URL, http.StatusTemporaryRedirect)
+			return
+		}
+		http.Redirect(w, r, "/hop", http.StatusTemporaryRedirect)
+	}))
+	defer redirect.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	opts := jsonrpcotel.ClientOptions(jsonrpcotel.WithTracerProvider(provider))
+	c := jsonrpc.NewClient(redirect.URL, append(opts, jsonrpc.WithRedirects(2))...)
+	if result, err := c.Execute(request{method: "ping"}); err != nil || result.At(0) != "pong" {
+		t.Fatalf("got %v, %v", result, err)
+	}
+
+	get := jsonrpc.NewClient(ts.URL, append(opts, jsonrpc.WithHTTPMethod(http.MethodGet), jsonrpc.BeforeRequest(jsonrpc.SignRequest([]byte("secret"))))...)
+	if _, err := get.Execute(request{method: "ping"}); err == nil {
+		t.Fatal("signing a GET request did not fail")
+	}
+
+	if started, ended := len(recorder.Started()), len(recorder.Ended()); started != 2 || ended != 2 {
+		t.Fatalf("started %d spans and ended %d, want 2", started, ended)
+	}
+}
@@ -0,0 +1,23 @@
+package jsonrpctest
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/555f/jsonrpc"
+)
+
+// NewTestServer serves s over HTTP until the test ends.
+func NewTestServer(t testing.TB, s *jsonrpc.Server) *httptest.Server {
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// NewTestClient serves s like NewTestServer and returns a client for it.
+func NewTestClient(t testing.TB, s *jsonrpc.Server, opts ...jsonrpc.ClientOption) *jsonrpc.Client {
+	ts := NewTestServer(t, s)
+	c := jsonrpc.NewClient(ts.URL, opts...)
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
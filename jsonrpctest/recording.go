@@ -0,0 +1,171 @@
+package jsonrpctest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Fixture is one recorded call. Exactly one of Result and Error is set.
+type Fixture struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+type message struct {
+	ID      json.RawMessage `json:"id,omitempty"`
+	Version string          `json:"jsonrpc,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
+}
+
+// RecordingTransport is an http.RoundTripper for use with WithHTTPClient
+// that records JSON-RPC calls made through a delegate, or replays
+// fixtures without touching the network.
+type RecordingTransport struct {
+	delegate http.RoundTripper
+	mu       sync.Mutex
+	fixtures []Fixture
+}
+
+func NewRecordingTransport(delegate http.RoundTripper) *RecordingTransport {
+	if delegate == nil {
+		delegate = http.DefaultTransport
+	}
+	return &RecordingTransport{delegate: delegate}
+}
+
+// NewPlaybackTransport answers every call with the first fixture recorded
+// for its method, or a Method not found error when there is none.
+func NewPlaybackTransport(fixtures []Fixture) *RecordingTransport {
+	return &RecordingTransport{fixtures: fixtures}
+}
+
+func (t *RecordingTransport) Fixtures() []Fixture {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Fixture(nil), t.fixtures...)
+}
+
+func decodeMessages(data []byte) ([]message, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if data[0] != '[' {
+		data = append(append([]byte("["), data...), ']')
+	}
+	var messages []message
+	err := json.Unmarshal(data, &messages)
+	return messages, err
+}
+
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	requests, err := decodeMessages(body)
+	if err != nil {
+		return nil, err
+	}
+	if t.delegate == nil {
+		return t.playback(req, requests)
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	resp, err := t.delegate.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	responses, err := decodeMessages(data)
+	if err != nil {
+		return resp, nil
+	}
+	byID := make(map[string]message, len(responses))
+	for _, m := range responses {
+		byID[string(m.ID)] = m
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, r := range requests {
+		m, ok := byID[string(r.ID)]
+		if !ok {
+			continue
+		}
+		t.fixtures = append(t.fixtures, Fixture{Method: r.Method, Params: r.Params, Result: m.Result, Error: m.Error})
+	}
+	return resp, nil
+}
+
+func (t *RecordingTransport) playback(req *http.Request, requests []message) (*http.Response, error) {
+	responses := make([]message, 0, len(requests))
+	t.mu.Lock()
+	for _, r := range requests {
+		if len(r.ID) == 0 {
+			continue
+		}
+		m := message{ID: r.ID, Version: "2.0", Error: json.RawMessage(`{"code":-32601,"message":"no fixture for ` + r.Method + `"}`)}
+		for _, f := range t.fixtures {
+			if f.Method == r.Method {
+				m.Result, m.Error = f.Result, f.Error
+				if m.Result == nil && m.Error == nil {
+					m.Result = json.RawMessage("null")
+				}
+				break
+			}
+		}
+		responses = append(responses, m)
+	}
+	t.mu.Unlock()
+	data, err := json.Marshal(responses)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}, nil
+}
+
+// SaveFixtures writes fixtures to path as a JSON file.
+func SaveFixtures(path string, fixtures []Fixture) error {
+	data, err := json.MarshalIndent(fixtures, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// LoadFixtures reads a file written by SaveFixtures.
+func LoadFixtures(path string) ([]Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixtures []Fixture
+	err = json.Unmarshal(data, &fixtures)
+	return fixtures, err
+}
@@ -0,0 +1,116 @@
+package jsonrpctest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/555f/jsonrpc"
+)
+
+type expectation struct {
+	method string
+	params any
+	result any
+	err    *jsonrpc.Error
+	met    bool
+}
+
+// MockServer is an HTTP JSON-RPC server that answers with canned results
+// and checks that every expected call was made.
+type MockServer struct {
+	t        testing.TB
+	server   *jsonrpc.Server
+	ts       *httptest.Server
+	mu       sync.Mutex
+	expected []*expectation
+	once     sync.Once
+}
+
+// NewMockServer starts a MockServer and returns it with a client pointed
+// at it. The server is closed, and its expectations checked, when the test
+// ends if Close was not called before.
+func NewMockServer(t testing.TB, opts ...jsonrpc.ClientOption) (*MockServer, *jsonrpc.Client) {
+	m := &MockServer{t: t, server: jsonrpc.NewServer()}
+	m.ts = httptest.NewServer(m.server)
+	t.Cleanup(m.Close)
+	return m, jsonrpc.NewClient(m.ts.URL, opts...)
+}
+
+func (m *MockServer) URL() string {
+	return m.ts.URL
+}
+
+// Expect answers one call of method with result. A nil params matches any
+// params; otherwise the call's params must equal params once both are
+// encoded as JSON.
+func (m *MockServer) Expect(method string, params any, result any) {
+	m.add(&expectation{method: method, params: params, result: result})
+}
+
+// ExpectError answers one call of method, with any params, with an error.
+func (m *MockServer) ExpectError(method string, code int, message string) {
+	m.add(&expectation{method: method, err: jsonrpc.NewError(code, message, nil)})
+}
+
+func (m *MockServer) add(e *expectation) {
+	m.mu.Lock()
+	m.expected = append(m.expected, e)
+	m.mu.Unlock()
+	method := e.method
+	m.server.Register(method, func(ctx context.Context, request interface{}) (interface{}, error) {
+		return m.call(method, request.(json.RawMessage))
+	}, func(ctx context.Context, r *http.Request, params json.RawMessage) (any, error) {
+		return params, nil
+	})
+}
+
+func (m *MockServer) call(method string, params json.RawMessage) (any, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.expected {
+		if e.met || e.method != method || !paramsMatch(e.params, params) {
+			continue
+		}
+		e.met = true
+		if e.err != nil {
+			return nil, e.err
+		}
+		return e.result, nil
+	}
+	m.t.Errorf("jsonrpctest: unexpected call %s(%s)", method, params)
+	return nil, jsonrpc.NewError(-32601, "unexpected call to "+method, nil)
+}
+
+func paramsMatch(want any, got json.RawMessage) bool {
+	if want == nil {
+		return true
+	}
+	data, err := json.Marshal(want)
+	if err != nil {
+		return false
+	}
+	var w, g any
+	if json.Unmarshal(data, &w) != nil || json.Unmarshal(got, &g) != nil {
+		return false
+	}
+	return reflect.DeepEqual(w, g)
+}
+
+// Close stops the server and reports every expectation that was not met.
+func (m *MockServer) Close() {
+	m.once.Do(func() {
+		m.ts.Close()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for _, e := range m.expected {
+			if !e.met {
+				m.t.Errorf("jsonrpctest: expected call to %s was not made", e.method)
+			}
+		}
+	})
+}
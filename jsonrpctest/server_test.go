@@ -0,0 +1,121 @@
+package jsonrpctest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/555f/jsonrpc"
+	"github.com/555f/jsonrpc/jsonrpctest"
+)
+
+type request struct {
+	method string
+	params any
+}
+
+func (r *request) MakeRequest() (string, any) {
+	return r.method, r.params
+}
+
+func (r *request) MakeResult(data []byte) (any, error) {
+	var v any
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+func TestMockServer(t *testing.T) {
+	m, c := jsonrpctest.NewMockServer(t)
+	m.Expect("getBalance", map[string]any{"address": "0xabc"}, 42)
+	m.ExpectError("transfer", -32000, "insufficient funds")
+
+	result, err := c.Execute(
+		&request{method: "getBalance", params: map[string]string{"address": "0xabc"}},
+		&request{method: "transfer", params: []int{1}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.At(0); got != float64(42) {
+		t.Fatalf("got balance %v", got)
+	}
+	var rpcErr *jsonrpc.Error
+	if !errors.As(result.Error(1), &rpcErr) || rpcErr.Code() != -32000 {
+		t.Fatalf("got error %v", result.Error(1))
+	}
+}
+
+func TestMockServerUnmetExpectation(t *testing.T) {
+	var failed bool
+	inner := &recorder{TB: t, failed: &failed}
+	m, _ := jsonrpctest.NewMockServer(inner)
+	m.Expect("never", nil, true)
+	m.Close()
+	if !failed {
+		t.Fatal("unmet expectation was not reported")
+	}
+}
+
+type recorder struct {
+	testing.TB
+	failed *bool
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	*r.failed = true
+}
+
+func TestNewTestClient(t *testing.T) {
+	s := jsonrpc.NewServer()
+	jsonrpc.RegisterFunc(s, "double", func(ctx context.Context, n *int) (*int, error) {
+		v := *n * 2
+		return &v, nil
+	})
+	c := jsonrpctest.NewTestClient(t, s)
+	result, err := c.Execute(&request{method: "double", params: 21})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.At(0); got != float64(42) {
+		t.Fatalf("got %v, want 42", got)
+	}
+}
+
+func TestRecordingTransport(t *testing.T) {
+	s := jsonrpc.NewServer()
+	jsonrpc.RegisterFunc(s, "double", func(ctx context.Context, n *int) (*int, error) {
+		v := *n * 2
+		return &v, nil
+	})
+	ts := jsonrpctest.NewTestServer(t, s)
+
+	recorder := jsonrpctest.NewRecordingTransport(nil)
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithHTTPClient(&http.Client{Transport: recorder}))
+	if _, err := c.Execute(&request{method: "double", params: 4}, &request{method: "missing"}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	if err := jsonrpctest.SaveFixtures(path, recorder.Fixtures()); err != nil {
+		t.Fatal(err)
+	}
+	fixtures, err := jsonrpctest.LoadFixtures(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) != 2 || string(fixtures[0].Result) != "8" || fixtures[1].Error == nil {
+		t.Fatalf("unexpected fixtures %+v", fixtures)
+	}
+
+	ts.Close()
+	c = jsonrpc.NewClient("http://jsonrpc.invalid", jsonrpc.WithHTTPClient(&http.Client{Transport: jsonrpctest.NewPlaybackTransport(fixtures)}))
+	result, err := c.Execute(&request{method: "double", params: 100}, &request{method: "missing"}, &request{method: "unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.At(0) != float64(8) || result.Error(1) == nil || result.Error(2) == nil {
+		t.Fatalf("unexpected playback results %v %v %v", result.At(0), result.Error(1), result.Error(2))
+	}
+}
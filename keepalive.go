@@ -0,0 +1,106 @@
+package jsonrpc
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// PingMethod is called by keepalive pings. Servers need not implement it:
+// any response, including method not found, shows the peer is alive.
+const PingMethod = "rpc.ping"
+
+// WithKeepalive makes WebSocket, stream and TCP clients send a PingMethod
+// request every interval once their connection is up. A ping without an
+// answer within timeout is reported to the OnKeepaliveError func. On
+// transports that dial again, WebSocket and NewTCPClient, it also drops
+// the connection, failing the calls in flight with ErrConnectionLost, and
+// the next tick or call dials again. Connections that cannot be replaced
+// are left open.
+func WithKeepalive(interval, timeout time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.keepaliveInterval = interval
+		o.keepaliveTimeout = timeout
+	}
+}
+
+// OnKeepaliveError registers f to be called with the error of every failed
+// keepalive ping or redial.
+func OnKeepaliveError(f func(err error)) ClientOption {
+	return func(o *clientOptions) {
+		o.onKeepaliveError = f
+	}
+}
+
+// OnReconnect registers f to run after a persistent connection was dialed
+// again, for example to re-establish subscriptions.
+func OnReconnect(f func(ctx context.Context)) ClientOption {
+	return func(o *clientOptions) {
+		o.onReconnect = f
+	}
+}
+
+// newMuxTransport returns a muxTransport set up with the client's
+// keepalive options. redial tells whether dial can be called again after
+// a connection failed.
+func (c *Client) newMuxTransport(dial func(ctx context.Context) (messageConn, error), redial bool) *muxTransport {
+	t := newMuxTransport(dial)
+	t.redial = redial
+	t.onReconnect = c.opts.onReconnect
+	if c.opts.keepaliveInterval > 0 {
+		t.keepalive = func() {
+			t.keepaliveLoop(c.opts.keepaliveInterval, c.opts.keepaliveTimeout, c.opts.onKeepaliveError)
+		}
+	}
+	return t
+}
+
+// keepaliveLoop runs from the first connection until the transport is
+// closed or, on a transport that cannot redial, its connection is gone.
+func (t *muxTransport) keepaliveLoop(interval, timeout time.Duration, onError func(error)) {
+	if timeout <= 0 {
+		timeout = interval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var n uint64
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+		}
+		t.mu.Lock()
+		conn := t.conn
+		t.mu.Unlock()
+		if conn == nil && !t.redial {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := t.ping(ctx, conn, strconv.FormatUint(atomic.AddUint64(&n, 1), 10))
+		cancel()
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// ping sends a PingMethod request on conn, or dials a new connection
+// first when conn is nil.
+func (t *muxTransport) ping(ctx context.Context, conn messageConn, id string) error {
+	if conn == nil {
+		var err error
+		if conn, err = t.connect(ctx); err != nil {
+			return err
+		}
+	}
+	payload := []byte(`{"jsonrpc":"2.0","id":"ping-` + id + `","method":"` + PingMethod + `"}`)
+	if _, _, err := t.RoundTrip(ctx, payload); err != nil {
+		if t.redial {
+			t.drop(conn)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/555f/jsonrpc"
+)
+
+func TestClientKeepalive(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		// The first peer reads but never answers, the next ones serve.
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() { _, _ = io.Copy(io.Discard, conn) }()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() { _ = s.ServeConn(conn) }()
+		}
+	}()
+
+	reconnected := make(chan struct{}, 1)
+	c := jsonrpc.NewTCPClient(ln.Addr().String(),
+		jsonrpc.WithKeepalive(20*time.Millisecond, 20*time.Millisecond),
+		jsonrpc.OnReconnect(func(context.Context) { reconnected <- struct{}{} }),
+	)
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := c.ExecuteWithContext(ctx, &echoRequest{method: "echo", params: "lost"}); !errors.Is(err, jsonrpc.ErrConnectionLost) {
+		t.Fatalf("got %v, want ErrConnectionLost", err)
+	}
+	select {
+	case <-reconnected:
+	case <-ctx.Done():
+		t.Fatal("OnReconnect was not called")
+	}
+	result, err := c.ExecuteWithContext(ctx, &echoRequest{method: "echo", params: "back"})
+	if err != nil || result.At(0) != "back" {
+		t.Fatalf("got %v, %v", result, err)
+	}
+}
+
+func TestClientKeepaliveWithoutRedial(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	var pings int64
+	s.Register(jsonrpc.PingMethod, func(ctx context.Context, request interface{}) (interface{}, error) {
+		if atomic.AddInt64(&pings, 1) == 1 {
+			time.Sleep(100 * time.Millisecond)
+		}
+		return "pong", nil
+	}, rawDecode)
+	serverConn, clientConn := net.Pipe()
+	go func() { _ = s.ServeConn(serverConn) }()
+
+	failed := make(chan error, 1)
+	c := jsonrpc.NewConnClient(clientConn,
+		jsonrpc.WithKeepalive(10*time.Millisecond, 10*time.Millisecond),
+		jsonrpc.OnKeepaliveError(func(err error) {
+			select {
+			case failed <- err:
+			default:
+			}
+		}),
+	)
+	defer c.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt64(&pings); n != 0 {
+		t.Fatalf("sent %d pings before the first call", n)
+	}
+	if _, err := c.Execute(&echoRequest{method: "echo", params: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-failed; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the slow ping to time out", err)
+	}
+	result, err := c.Execute(&echoRequest{method: "echo", params: "still up"})
+	if err != nil || result.At(0) != "still up" {
+		t.Fatalf("got %v, %v", result, err)
+	}
+}
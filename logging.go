@@ -0,0 +1,97 @@
+package jsonrpc
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+type logOptions struct {
+	params    bool
+	redact    func(method string, params any) any
+	bodyLimit int
+}
+
+type LogOption func(*logOptions)
+
+// LogParams includes request params in the log records. Params pass through
+// the LogRedact hook first when one is set.
+func LogParams() LogOption {
+	return func(o *logOptions) {
+		o.params = true
+	}
+}
+
+func LogRedact(redact func(method string, params any) any) LogOption {
+	return func(o *logOptions) {
+		o.redact = redact
+	}
+}
+
+// LogBodyLimit enables response body logging, truncated to n bytes. The
+// same limit caps the encoded params of each logged request.
+func LogBodyLimit(n int) LogOption {
+	return func(o *logOptions) {
+		o.bodyLimit = n
+	}
+}
+
+func WithLogging(logger *slog.Logger, opts ...LogOption) ClientOption {
+	return func(o *clientOptions) {
+		o.logger = logger
+		for _, opt := range opts {
+			opt(&o.logOpts)
+		}
+	}
+}
+
+func truncate(data []byte, limit int) string {
+	if limit > 0 && len(data) > limit {
+		return string(data[:limit]) + "..."
+	}
+	return string(data)
+}
+
+func (c *Client) logRequest(ctx context.Context, requests []clientReq, size int) {
+	entries := make([]any, 0, len(requests))
+	for i, r := range requests {
+		attrs := []any{slog.String("method", r.Method), slog.Any("id", r.ID)}
+		if c.opts.logOpts.params {
+			params := r.Params
+			if c.opts.logOpts.redact != nil {
+				params = c.opts.logOpts.redact(r.Method, params)
+			}
+			data, err := c.opts.codec.Marshal(params)
+			if err == nil {
+				attrs = append(attrs, slog.String("params", truncate(data, c.opts.logOpts.bodyLimit)))
+			}
+		}
+		entries = append(entries, slog.Group(strconv.Itoa(i), attrs...))
+	}
+	c.opts.logger.DebugContext(ctx, "jsonrpc request", requestIDAttrs(ctx, []any{
+		slog.Int("batch_size", len(requests)),
+		slog.Int("bytes", size),
+		slog.Group("requests", entries...),
+	})...)
+}
+
+func (c *Client) logResponse(call *clientCall, dur time.Duration, err error) {
+	attrs := []any{
+		slog.Any("methods", call.methods),
+		slog.Duration("duration", dur),
+		slog.Int("bytes", len(call.data)),
+	}
+	attrs = requestIDAttrs(call.ctx, attrs)
+	if resp := call.meta.Response; resp != nil {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+	}
+	if err != nil {
+		c.opts.logger.ErrorContext(call.ctx, "jsonrpc response", append(attrs, slog.String("error", err.Error()))...)
+		return
+	}
+	if c.opts.logOpts.bodyLimit > 0 {
+		attrs = append(attrs, slog.String("body", truncate(call.data, c.opts.logOpts.bodyLimit)))
+	}
+	c.opts.logger.DebugContext(call.ctx, "jsonrpc response", attrs...)
+}
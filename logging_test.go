@@ -0,0 +1,71 @@
+package jsonrpc_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/555f/jsonrpc"
+)
+
+func TestClientLogging(t *testing.T) {
+	ts := newEchoServer(t)
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithLogging(logger,
+		jsonrpc.LogParams(),
+		jsonrpc.LogRedact(func(method string, params any) any {
+			if method == "echo" {
+				return "[REDACTED]"
+			}
+			return params
+		}),
+		jsonrpc.LogBodyLimit(16),
+	))
+	if _, err := c.Execute(&echoRequest{method: "echo", params: "private-key-0123456789"}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "private-key") {
+		t.Fatalf("params leaked into logs: %s", out)
+	}
+	for _, want := range []string{`"method":"echo"`, `[REDACTED]`, `"duration"`, `"status":200`, `..."`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log output is missing %s: %s", want, out)
+		}
+	}
+}
+
+func TestServerXRequestID(t *testing.T) {
+	downstream := newEchoServer(t)
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c := jsonrpc.NewClient(downstream.URL, jsonrpc.WithLogging(logger))
+
+	s := jsonrpc.NewServer(jsonrpc.WithXRequestID(func() string { return "generated" }))
+	s.Register("proxy", func(ctx context.Context, request interface{}) (interface{}, error) {
+		if _, err := c.ExecuteWithContext(ctx, &echoRequest{method: "echo", params: 1}); err != nil {
+			return nil, err
+		}
+		return jsonrpc.RequestIDFromContext(ctx), nil
+	}, rawDecode)
+
+	w := serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"proxy"}`)
+	if got := w.Header().Get("X-Request-ID"); got != "generated" || !strings.Contains(w.Body.String(), `"result":"generated"`) {
+		t.Fatalf("got header %q body %s", got, w.Body.String())
+	}
+	if n := strings.Count(buf.String(), `"request_id":"generated"`); n != 2 {
+		t.Fatalf("request_id appears in %d log records, want 2: %s", n, buf.String())
+	}
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"id":1,"jsonrpc":"2.0","method":"proxy"}`))
+	r.Header.Set("X-Request-ID", "abc")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, r)
+	if got := rec.Header().Get("X-Request-ID"); got != "abc" {
+		t.Fatalf("incoming id was replaced by %q", got)
+	}
+}
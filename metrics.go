@@ -0,0 +1,65 @@
+package jsonrpc
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	ErrorClassEncode     = "encode"
+	ErrorClassTransport  = "transport"
+	ErrorClassHTTPStatus = "http_status"
+	ErrorClassDecode     = "decode"
+	ErrorClassRPC        = "rpc"
+)
+
+// ClientObservation describes the outcome of one batch entry. Duration and
+// byte counts cover the whole HTTP exchange the entry was part of.
+type ClientObservation struct {
+	Method        string
+	Index         int
+	BatchSize     int
+	Duration      time.Duration
+	BytesSent     int
+	BytesReceived int
+	HTTPStatus    int
+	ErrorCode     int
+	ErrorClass    string
+	Err           error
+}
+
+type ObserverFunc func(ctx context.Context, o ClientObservation)
+
+func WithClientMetrics(observer ...ObserverFunc) ClientOption {
+	return func(o *clientOptions) {
+		o.observers = append(o.observers, observer...)
+	}
+}
+
+func (c *Client) observe(dur time.Duration, call *clientCall, batchResult *BatchResult, err error) {
+	base := ClientObservation{
+		BatchSize:     len(call.methods),
+		Duration:      dur,
+		BytesSent:     call.bytesSent,
+		BytesReceived: len(call.data),
+	}
+	if resp := call.meta.Response; resp != nil {
+		base.HTTPStatus = resp.StatusCode
+	}
+	for i, method := range call.methods {
+		o := base
+		o.Method = method
+		o.Index = i
+		if err != nil {
+			o.ErrorClass = call.errClass
+			o.Err = err
+		} else if rpcErr, ok := batchResult.results[i].(*Error); ok {
+			o.ErrorClass = ErrorClassRPC
+			o.ErrorCode = rpcErr.code
+			o.Err = rpcErr
+		}
+		for _, observer := range c.opts.observers {
+			observer(call.ctx, o)
+		}
+	}
+}
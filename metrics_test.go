@@ -0,0 +1,107 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/555f/jsonrpc"
+)
+
+func expvarObserver(calls, errs *expvar.Map) jsonrpc.ObserverFunc {
+	return func(ctx context.Context, o jsonrpc.ClientObservation) {
+		calls.Add(o.Method, 1)
+		if o.ErrorClass != "" {
+			errs.Add(o.Method+":"+o.ErrorClass, 1)
+		}
+	}
+}
+
+func TestClientMetrics(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	s.Register("fail", func(ctx context.Context, request interface{}) (interface{}, error) {
+		return nil, errors.New("boom")
+	}, rawDecode)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	calls, errs := new(expvar.Map).Init(), new(expvar.Map).Init()
+	var observations []jsonrpc.ClientObservation
+	observer := expvarObserver(calls, errs)
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithClientMetrics(func(ctx context.Context, o jsonrpc.ClientObservation) {
+		observer(ctx, o)
+		observations = append(observations, o)
+	}))
+	if _, err := c.Execute(&echoRequest{method: "echo", params: 1}, &echoRequest{method: "fail"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := calls.Get("echo").String(); got != "1" {
+		t.Fatalf("echo calls = %s, want 1", got)
+	}
+	if got := errs.Get("fail:rpc").String(); got != "1" {
+		t.Fatalf("fail rpc errors = %v, want 1", errs.Get("fail:rpc"))
+	}
+	o := observations[1]
+	if o.BatchSize != 2 || o.Index != 1 || o.ErrorCode != -32603 || o.HTTPStatus != 200 || o.BytesSent == 0 || o.BytesReceived == 0 {
+		t.Fatalf("unexpected observation %+v", o)
+	}
+
+	ts.Close()
+	if _, err := c.Execute(&echoRequest{method: "echo", params: 1}); err == nil {
+		t.Fatal("expected transport error")
+	}
+	if got := errs.Get("echo:transport"); got == nil || got.String() != "1" {
+		t.Fatalf("echo transport errors = %v, want 1", got)
+	}
+}
+
+type observation struct {
+	method string
+	code   int
+	dur    time.Duration
+}
+
+type recordingObserver struct {
+	mu           sync.Mutex
+	observations []observation
+}
+
+func (o *recordingObserver) ObserveRequest(method string, code int, dur time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.observations = append(o.observations, observation{method, code, dur})
+}
+
+func TestServerMetrics(t *testing.T) {
+	observer := &recordingObserver{}
+	s := jsonrpc.NewServer(jsonrpc.WithMetrics(observer))
+	s.Register("slow", func(ctx context.Context, request interface{}) (interface{}, error) {
+		time.Sleep(5 * time.Millisecond)
+		return "ok", nil
+	}, rawDecode)
+	s.Register("fail", func(ctx context.Context, request interface{}) (interface{}, error) {
+		return nil, jsonrpc.NewError(-32042, "boom", nil)
+	}, rawDecode)
+
+	serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"slow"}`)
+	serve(t, s, `{"id":2,"jsonrpc":"2.0","method":"fail"}`)
+	serve(t, s, `[{"id":3,"jsonrpc":"2.0","method":"slow"},{"id":4,"jsonrpc":"2.0","method":"fail"},{"id":5,"jsonrpc":"2.0","method":"missing"}]`)
+
+	want := []observation{{"slow", 0, 0}, {"fail", -32042, 0}, {"slow", 0, 0}, {"fail", -32042, 0}, {"missing", -32601, 0}}
+	if len(observer.observations) != len(want) {
+		t.Fatalf("got %d observations, want %d", len(observer.observations), len(want))
+	}
+	for i, got := range observer.observations {
+		if got.method != want[i].method || got.code != want[i].code {
+			t.Fatalf("observation %d = %+v, want method %s code %d", i, got, want[i].method, want[i].code)
+		}
+		if got.method == "slow" && got.dur < 5*time.Millisecond {
+			t.Fatalf("observation %d took %v, want at least 5ms", i, got.dur)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+)
+
+type Caller interface {
+	Execute(requests ...Requester) (*BatchResult, error)
+	ExecuteWithContext(ctx context.Context, requests ...Requester) (*BatchResult, error)
+	RawExecuteWithContext(ctx context.Context, requests ...Requester) ([]byte, map[uint64]int, *http.Response, error)
+}
+
+var _ Caller = (*Client)(nil)
+
+type mockResponse struct {
+	result json.RawMessage
+	err    *jsonRPCError
+}
+
+// MockClient is a Caller for tests that answers from canned results
+// registered per method name. Requests still go through the real encoder
+// and every Requester's MakeRequest/MakeResult, so serialization bugs in
+// request types are not hidden by the mock.
+type MockClient struct {
+	client    *Client
+	mu        sync.Mutex
+	responses map[string]mockResponse
+	requests  []Requester
+}
+
+var _ Caller = (*MockClient)(nil)
+
+func NewMockClient(opts ...ClientOption) *MockClient {
+	m := &MockClient{responses: make(map[string]mockResponse)}
+	m.client = NewClient("", append(opts, WithTransport(mockTransport{m: m}))...)
+	return m
+}
+
+func (m *MockClient) On(method string, result any) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.responses[method] = mockResponse{result: data}
+	return nil
+}
+
+func (m *MockClient) OnError(method string, err *Error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.responses[method] = mockResponse{err: &jsonRPCError{Code: err.code, Message: err.message, Data: err.data}}
+}
+
+func (m *MockClient) Requests() []Requester {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Requester(nil), m.requests...)
+}
+
+func (m *MockClient) record(requests []Requester) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, requests...)
+}
+
+func (m *MockClient) Execute(requests ...Requester) (*BatchResult, error) {
+	return m.ExecuteWithContext(context.TODO(), requests...)
+}
+
+func (m *MockClient) ExecuteWithContext(ctx context.Context, requests ...Requester) (*BatchResult, error) {
+	m.record(requests)
+	return m.client.ExecuteWithContext(ctx, requests...)
+}
+
+func (m *MockClient) RawExecuteWithContext(ctx context.Context, requests ...Requester) ([]byte, map[uint64]int, *http.Response, error) {
+	m.record(requests)
+	return m.client.RawExecuteWithContext(ctx, requests...)
+}
+
+type mockTransport struct {
+	m *MockClient
+}
+
+func (t mockTransport) RoundTrip(ctx context.Context, payload []byte) (io.ReadCloser, TransportMeta, error) {
+	var requests []Request
+	if err := json.Unmarshal(payload, &requests); err != nil {
+		return nil, TransportMeta{}, err
+	}
+	t.m.mu.Lock()
+	responses := make([]jsonRPCResponse, 0, len(requests))
+	for _, req := range requests {
+		if req.ID.notification() {
+			continue
+		}
+		resp, ok := t.m.responses[req.Method]
+		switch {
+		case !ok:
+			responses = append(responses, jsonRPCResponse{ID: req.ID, Version: Version, Error: &jsonRPCError{Code: jsonRPCMethodNotFoundError, Message: "method " + req.Method + " not found"}})
+		case resp.err != nil:
+			responses = append(responses, jsonRPCResponse{ID: req.ID, Version: Version, Error: resp.err})
+		default:
+			responses = append(responses, jsonRPCResponse{ID: req.ID, Version: Version, Result: resp.result})
+		}
+	}
+	t.m.mu.Unlock()
+	data, err := json.Marshal(responses)
+	if err != nil {
+		return nil, TransportMeta{}, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), TransportMeta{}, nil
+}
@@ -0,0 +1,37 @@
+package jsonrpc_test
+
+import (
+	"testing"
+
+	"github.com/555f/jsonrpc"
+)
+
+func TestMockClient(t *testing.T) {
+	m := jsonrpc.NewMockClient()
+	if err := m.On("echo", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	m.OnError("fail", jsonrpc.NewError(-32000, "boom", nil))
+
+	var caller jsonrpc.Caller = m
+	result, err := caller.Execute(&echoRequest{method: "echo"}, &echoRequest{method: "fail"}, &echoRequest{method: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := result.At(0).(map[string]any); !ok || got["n"] != float64(1) {
+		t.Fatalf("got %v", result.At(0))
+	}
+	if err := result.Error(1); err == nil || err.(*jsonrpc.Error).Code() != -32000 {
+		t.Fatalf("got %v, want canned error", err)
+	}
+	if err := result.Error(2); err == nil || err.(*jsonrpc.Error).Code() != -32601 {
+		t.Fatalf("got %v, want method not found", err)
+	}
+	if got := len(m.Requests()); got != 3 {
+		t.Fatalf("recorded %d requests, want 3", got)
+	}
+
+	if _, err := m.Execute(&echoRequest{method: "echo", params: func() {}}); err == nil {
+		t.Fatal("expected unencodable params to fail")
+	}
+}
@@ -0,0 +1,207 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+)
+
+var errConnClosed = errors.New("jsonrpc: connection closed")
+
+// ErrConnectionLost fails the calls in flight on a persistent connection
+// when it breaks or stops answering keepalive pings.
+var ErrConnectionLost = errors.New("jsonrpc: connection lost")
+
+type messageConn interface {
+	ReadMessage() ([]byte, error)
+	WriteMessage(data []byte) error
+	Close() error
+}
+
+type muxCall struct {
+	ids  []string
+	resp chan []byte
+	err  chan error
+}
+
+// muxTransport multiplexes concurrent round trips over a single
+// message-oriented connection, correlating responses by request id.
+type muxTransport struct {
+	dial func(ctx context.Context) (messageConn, error)
+
+	mu      sync.Mutex
+	writeMu sync.Mutex
+	conn    messageConn
+	pending map[string]*muxCall
+	closed  bool
+
+	connected   bool
+	redial      bool
+	onReconnect func(ctx context.Context)
+	keepalive   func()
+	done        chan struct{}
+}
+
+func newMuxTransport(dial func(ctx context.Context) (messageConn, error)) *muxTransport {
+	return &muxTransport{dial: dial, pending: make(map[string]*muxCall), done: make(chan struct{})}
+}
+
+func (t *muxTransport) connect(ctx context.Context) (messageConn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, errConnClosed
+	}
+	if t.conn != nil {
+		return t.conn, nil
+	}
+	conn, err := t.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t.conn = conn
+	go t.readLoop(conn)
+	if t.connected && t.onReconnect != nil {
+		go t.onReconnect(context.WithoutCancel(ctx))
+	}
+	if !t.connected && t.keepalive != nil {
+		go t.keepalive()
+	}
+	t.connected = true
+	return conn, nil
+}
+
+func (t *muxTransport) readLoop(conn messageConn) {
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			t.drop(conn)
+			return
+		}
+		ids := messageIDs(data)
+		if len(ids) == 0 {
+			continue
+		}
+		t.mu.Lock()
+		call, ok := t.pending[ids[0]]
+		if ok {
+			t.unregister(call)
+		}
+		t.mu.Unlock()
+		if ok {
+			call.resp <- data
+		}
+	}
+}
+
+// drop forgets conn, failing the calls in flight on it unless it was
+// replaced or the transport closed already.
+func (t *muxTransport) drop(conn messageConn) {
+	t.mu.Lock()
+	if t.conn == conn && !t.closed {
+		t.conn = nil
+		t.failPending(ErrConnectionLost)
+	}
+	t.mu.Unlock()
+	t.closeConn(conn)
+}
+
+func (t *muxTransport) closeConn(conn messageConn) {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_ = conn.Close()
+}
+
+func (t *muxTransport) failPending(err error) {
+	for _, call := range t.pending {
+		t.unregister(call)
+		call.err <- err
+	}
+}
+
+func (t *muxTransport) unregister(call *muxCall) {
+	for _, id := range call.ids {
+		if t.pending[id] == call {
+			delete(t.pending, id)
+		}
+	}
+}
+
+func (t *muxTransport) RoundTrip(ctx context.Context, payload []byte) (io.ReadCloser, TransportMeta, error) {
+	conn, err := t.connect(ctx)
+	if err != nil {
+		return nil, TransportMeta{}, err
+	}
+	call := &muxCall{ids: messageIDs(payload), resp: make(chan []byte, 1), err: make(chan error, 1)}
+	t.mu.Lock()
+	for _, id := range call.ids {
+		t.pending[id] = call
+	}
+	t.mu.Unlock()
+
+	t.writeMu.Lock()
+	err = conn.WriteMessage(payload)
+	t.writeMu.Unlock()
+	if err != nil {
+		t.mu.Lock()
+		t.unregister(call)
+		t.mu.Unlock()
+		t.closeConn(conn)
+		return nil, TransportMeta{}, err
+	}
+
+	if len(call.ids) == 0 {
+		return io.NopCloser(bytes.NewReader(nil)), TransportMeta{}, nil
+	}
+	select {
+	case data := <-call.resp:
+		return io.NopCloser(bytes.NewReader(data)), TransportMeta{}, nil
+	case err := <-call.err:
+		return nil, TransportMeta{}, err
+	case <-ctx.Done():
+		t.mu.Lock()
+		t.unregister(call)
+		t.mu.Unlock()
+		return nil, TransportMeta{}, ctx.Err()
+	}
+}
+
+func (t *muxTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	close(t.done)
+	t.failPending(errConnClosed)
+	if t.conn == nil {
+		return nil
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	return t.conn.Close()
+}
+
+func messageIDs(data []byte) []string {
+	var messages []struct {
+		ID json.RawMessage `json:"id"`
+	}
+	data = bytes.TrimSpace(data)
+	if !bytes.HasPrefix(data, []byte("[")) {
+		data = append(append([]byte("["), data...), ']')
+	}
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil
+	}
+	ids := make([]string, 0, len(messages))
+	for _, m := range messages {
+		if len(m.ID) > 0 && string(m.ID) != "null" {
+			ids = append(ids, string(m.ID))
+		}
+	}
+	return ids
+}
@@ -0,0 +1,85 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+)
+
+// NamedParamsDecode returns a ReqDecode that accepts params both as an
+// object and as an array and decodes them into a new T before calling fn.
+// Objects are decoded as usual. Array elements are assigned to the fields
+// of a struct T by their `jsonrpc:"<index>"` tag, or in field order when
+// no field is tagged. Decoding failures are -32602 errors.
+func NamedParamsDecode[T any](fn func(ctx context.Context, r *http.Request, t *T) error) ReqDecode {
+	return func(ctx context.Context, r *http.Request, params json.RawMessage) (any, error) {
+		t := new(T)
+		if err := decodeParams(params, t); err != nil {
+			return nil, NewError(jsonRPCInvalidParamsError, err.Error(), nil)
+		}
+		if fn != nil {
+			if err := fn(ctx, r, t); err != nil {
+				return nil, err
+			}
+		}
+		return t, nil
+	}
+}
+
+func decodeParams(params json.RawMessage, target any) error {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	v := reflect.ValueOf(target).Elem()
+	if params[0] != '[' || v.Kind() != reflect.Struct {
+		return json.Unmarshal(params, target)
+	}
+	var elems []json.RawMessage
+	if err := json.Unmarshal(params, &elems); err != nil {
+		return err
+	}
+	fields := positionalFields(v.Type())
+	if len(elems) > len(fields) {
+		return fmt.Errorf("jsonrpc: got %d params, want at most %d", len(elems), len(fields))
+	}
+	for i, elem := range elems {
+		f, ok := fields[i]
+		if !ok {
+			return fmt.Errorf("jsonrpc: no field for param %d", i)
+		}
+		if err := json.Unmarshal(elem, v.FieldByIndex(f).Addr().Interface()); err != nil {
+			return fmt.Errorf("jsonrpc: param %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func positionalFields(t reflect.Type) map[int][]int {
+	tagged := map[int][]int{}
+	var ordered [][]int
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous || f.Tag.Get("json") == "-" {
+			continue
+		}
+		if tag, ok := f.Tag.Lookup("jsonrpc"); ok {
+			if i, err := strconv.Atoi(tag); err == nil {
+				tagged[i] = f.Index
+			}
+			continue
+		}
+		ordered = append(ordered, f.Index)
+	}
+	if len(tagged) > 0 {
+		return tagged
+	}
+	fields := make(map[int][]int, len(ordered))
+	for i, index := range ordered {
+		fields[i] = index
+	}
+	return fields
+}
@@ -0,0 +1,50 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"net/http"
+	"reflect"
+)
+
+const legacyVersion = "1.0"
+
+// WithProtocolVersion selects the protocol the client speaks. The default
+// is "2.0". With "1.0" requests carry no jsonrpc member, params are always
+// sent as an array, and every request goes in its own call since 1.0 has
+// no batches; responses with "error": null are accepted either way.
+func WithProtocolVersion(version string) ClientOption {
+	return func(o *clientOptions) {
+		o.version = version
+	}
+}
+
+func (c *Client) legacy() bool {
+	return c.opts.version == legacyVersion
+}
+
+// unbatched reports whether every request must be sent as a single object
+// in a call of its own.
+func (c *Client) unbatched() bool {
+	return c.legacy() || c.opts.httpMethod == http.MethodGet
+}
+
+// singleResponse reports whether data is the lone response object an
+// unbatched call may be answered with instead of an array.
+func (c *Client) singleResponse(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return c.unbatched() && len(data) > 0 && data[0] == '{'
+}
+
+// positionalParams coerces params into the array 1.0 servers expect.
+func positionalParams(params any) any {
+	if params == nil || params == NoParams {
+		return []any{}
+	}
+	switch reflect.TypeOf(params).Kind() {
+	case reflect.Slice, reflect.Array:
+		if _, ok := params.([]byte); !ok {
+			return params
+		}
+	}
+	return []any{params}
+}
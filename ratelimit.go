@@ -0,0 +1,64 @@
+package jsonrpc
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// KeyedLimiter decides whether one more call is allowed for key.
+type KeyedLimiter interface {
+	Allow(key string) bool
+}
+
+type rateLimitKey struct{}
+
+// ContextWithRateLimitKey stores the key RateLimit limits by, typically
+// from a BeforeFunc that extracts an API token or client address.
+func ContextWithRateLimitKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, rateLimitKey{}, key)
+}
+
+func RateLimitKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(rateLimitKey{}).(string)
+	return key
+}
+
+// RateLimit returns endpoint middleware that rejects calls over limiter's
+// rate for the key in the context with a -32000 error. Calls without a key
+// share the "" key. Pass it to Register to give methods their own limits.
+func RateLimit(limiter KeyedLimiter) EndpointMiddlewareFunc {
+	return func(next Endpoint) Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			if !limiter.Allow(RateLimitKeyFromContext(ctx)) {
+				return nil, NewError(jsonRPCServerError, "rate limit exceeded", nil)
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+type tokenBucketLimiter struct {
+	rps      rate.Limit
+	burst    int
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// NewKeyedLimiter returns a KeyedLimiter with a token bucket of rps and
+// burst per key. Buckets are kept for the life of the limiter.
+func NewKeyedLimiter(rps float64, burst int) KeyedLimiter {
+	return &tokenBucketLimiter{rps: rate.Limit(rps), burst: burst, limiters: make(map[string]*rate.Limiter)}
+}
+
+func (l *tokenBucketLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	limiter, ok := l.limiters[key]
+	if !ok {
+		limiter = rate.NewLimiter(l.rps, l.burst)
+		l.limiters[key] = limiter
+	}
+	l.mu.Unlock()
+	return limiter.Allow()
+}
@@ -0,0 +1,121 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+type reauth struct {
+	refresh func(ctx context.Context) (string, error)
+	match   func(httpStatus int, rpcCode int) bool
+
+	mu         sync.Mutex
+	token      string
+	gen        uint64
+	refreshing *refreshCall
+}
+
+type refreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+// WithReauth retries a call once with fresh credentials when it fails
+// with an auth error. match is given the HTTP status of a failed call, or
+// the code of each JSON-RPC error in the result with a status of 0; by
+// default it matches 401 and ErrorCodeUnauthorized. onAuthError then
+// returns the new bearer token, which is sent in the Authorization header
+// from then on. Concurrent calls that hit the same expiry share a single
+// refresh.
+func WithReauth(onAuthError func(ctx context.Context) (newToken string, err error), match func(httpStatus int, rpcCode int) bool) ClientOption {
+	if match == nil {
+		match = func(httpStatus int, rpcCode int) bool {
+			return httpStatus == http.StatusUnauthorized || rpcCode == ErrorCodeUnauthorized
+		}
+	}
+	a := &reauth{refresh: onAuthError, match: match}
+	return func(o *clientOptions) {
+		o.before = append(o.before, a.setToken)
+		o.middleware = append(o.middleware, a.middleware)
+	}
+}
+
+func (a *reauth) setToken(ctx context.Context, r *http.Request) context.Context {
+	a.mu.Lock()
+	token := a.token
+	a.mu.Unlock()
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return ctx
+}
+
+func (a *reauth) middleware(next ExecuteFunc) ExecuteFunc {
+	return func(ctx context.Context, requests []Requester) (*BatchResult, error) {
+		a.mu.Lock()
+		gen := a.gen
+		a.mu.Unlock()
+		result, err := next(ctx, requests)
+		if !a.failed(result, err) {
+			return result, err
+		}
+		if err := a.renew(ctx, gen); err != nil {
+			return result, err
+		}
+		return next(ctx, requests)
+	}
+}
+
+func (a *reauth) failed(result *BatchResult, err error) bool {
+	var transportErr *TransportError
+	if errors.As(err, &transportErr) && transportErr.StatusCode != 0 {
+		return a.match(transportErr.StatusCode, 0)
+	}
+	if err != nil || result == nil {
+		return false
+	}
+	for i := range result.results {
+		var rpcErr *Error
+		if errors.As(result.Error(i), &rpcErr) && a.match(0, rpcErr.code) {
+			return true
+		}
+	}
+	return false
+}
+
+// renew refreshes the token unless another call has already done so since
+// gen was observed. Calls arriving while a refresh runs wait for it, or
+// for their ctx, without holding a.mu.
+func (a *reauth) renew(ctx context.Context, gen uint64) error {
+	a.mu.Lock()
+	if a.gen != gen {
+		a.mu.Unlock()
+		return nil
+	}
+	if call := a.refreshing; call != nil {
+		a.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	call := &refreshCall{done: make(chan struct{})}
+	a.refreshing = call
+	a.mu.Unlock()
+
+	token, err := a.refresh(ctx)
+	a.mu.Lock()
+	if err == nil {
+		a.token = token
+		a.gen++
+	}
+	a.refreshing = nil
+	a.mu.Unlock()
+	call.err = err
+	close(call.done)
+	return err
+}
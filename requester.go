@@ -0,0 +1,146 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// NamedParams are by-name params that encode in insertion order, so the
+// same call always produces the same bytes.
+type NamedParams struct {
+	keys   []string
+	values []any
+	err    error
+}
+
+// Named builds by-name params from alternating keys and values. Invalid
+// arguments do not panic; the error is reported when the request is
+// encoded, failing the Execute call.
+func Named(kv ...any) NamedParams {
+	if len(kv)%2 != 0 {
+		return NamedParams{err: fmt.Errorf("jsonrpc: Named needs key/value pairs, got %d arguments", len(kv))}
+	}
+	p := NamedParams{keys: make([]string, 0, len(kv)/2), values: make([]any, 0, len(kv)/2)}
+	for i := 0; i < len(kv); i += 2 {
+		key, ok := kv[i].(string)
+		if !ok {
+			return NamedParams{err: fmt.Errorf("jsonrpc: Named key at argument %d is %T, not string", i, kv[i])}
+		}
+		p.keys = append(p.keys, key)
+		p.values = append(p.values, kv[i+1])
+	}
+	return p
+}
+
+func (p NamedParams) Err() error {
+	return p.err
+}
+
+func (p NamedParams) MarshalJSON() ([]byte, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range p.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(p.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// GenericRequester is a Requester for one-off calls. The result is
+// unmarshaled into Result, which should be a pointer, and that pointer is
+// returned as the entry's result; with a nil Result it is decoded into
+// an any.
+type GenericRequester struct {
+	Method string
+	Params any
+	Result any
+}
+
+func (r *GenericRequester) MakeRequest() (string, any) {
+	return r.Method, r.Params
+}
+
+func (r *GenericRequester) MakeResult(data []byte) (any, error) {
+	if r.Result == nil {
+		var v any
+		err := json.Unmarshal(data, &v)
+		return v, err
+	}
+	if err := json.Unmarshal(data, r.Result); err != nil {
+		return nil, err
+	}
+	return r.Result, nil
+}
+
+// RequesterWithValidate is implemented by requests that can check
+// themselves before anything is sent. A failure aborts the whole Execute
+// or RawExecute call.
+type RequesterWithValidate interface {
+	Validate() error
+}
+
+func validateRequests(requests []Requester) error {
+	for i, request := range requests {
+		v, ok := request.(RequesterWithValidate)
+		if !ok {
+			continue
+		}
+		if err := v.Validate(); err != nil {
+			method, _ := request.MakeRequest()
+			return fmt.Errorf("jsonrpc: invalid request for method %q (index %d): %w", method, i, err)
+		}
+	}
+	return nil
+}
+
+// RequesterWithNotification is implemented by requests that may be sent as
+// notifications. A notification has no id and gets no response, so its
+// BatchResult entry stays nil with a nil error.
+type RequesterWithNotification interface {
+	Notification() bool
+}
+
+func isNotification(r Requester) bool {
+	v, ok := r.(RequesterWithNotification)
+	return ok && v.Notification()
+}
+
+type call[T any] struct {
+	method string
+	params any
+}
+
+// Call returns a Requester for method whose result is decoded into a new
+// T; the BatchResult entry holds the *T.
+func Call[T any](method string, params any) Requester {
+	return &call[T]{method: method, params: params}
+}
+
+func (c *call[T]) MakeRequest() (string, any) {
+	return c.method, c.params
+}
+
+func (c *call[T]) MakeResult(data []byte) (any, error) {
+	v := new(T)
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
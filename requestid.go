@@ -0,0 +1,36 @@
+package jsonrpc
+
+import (
+	"context"
+	"log/slog"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// WithXRequestID makes the server take the X-Request-ID header of each
+// request, or a fresh id from generator when it is absent, store it in the
+// context and echo it in the response header. Client log records made
+// with that context through WithLogging carry it as request_id.
+func WithXRequestID(generator func() string) Option {
+	return func(o *Options) {
+		o.requestID = generator
+	}
+}
+
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func requestIDAttrs(ctx context.Context, attrs []any) []any {
+	if id := RequestIDFromContext(ctx); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	return attrs
+}
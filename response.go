@@ -0,0 +1,66 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+// Response is a single response as seen by response middleware. Error is
+// nil on success; Extensions are added as extra top-level members and may
+// not replace id, jsonrpc, result or error.
+type Response struct {
+	ID         RPCID
+	Result     json.RawMessage
+	Error      *Error
+	Extensions map[string]any
+}
+
+type ResponseMiddlewareFunc func(ctx context.Context, method string, resp *Response)
+
+// ResponseMiddleware registers funcs that may rewrite a response before it
+// is encoded. They run in order after the endpoint middleware chain and
+// the After funcs have finished and the result has been marshaled, and
+// also see error responses. Streamed responses and notifications
+// answered with NoResult skip them.
+func ResponseMiddleware(middleware ...ResponseMiddlewareFunc) Option {
+	return func(o *Options) {
+		o.response = append(o.response, middleware...)
+	}
+}
+
+func (s *Server) applyResponseMiddleware(ctx context.Context, middleware []ResponseMiddlewareFunc, method string, r jsonRPCResponse) jsonRPCResponse {
+	resp := &Response{ID: r.ID, Result: r.Result, Extensions: r.extensions}
+	if r.Error != nil {
+		resp.Error = &Error{code: r.Error.Code, message: r.Error.Message, data: r.Error.Data}
+	}
+	for _, m := range middleware {
+		m(ctx, method, resp)
+	}
+	r = jsonRPCResponse{ID: resp.ID, Version: Version, Result: resp.Result, extensions: resp.Extensions}
+	if resp.Error != nil {
+		r.Error = &jsonRPCError{Code: resp.Error.code, Message: resp.Error.message, Data: resp.Error.data}
+	}
+	return r
+}
+
+func appendExtensions(b []byte, extensions map[string]any) ([]byte, error) {
+	ext := make(map[string]any, len(extensions))
+	for k, v := range extensions {
+		switch k {
+		case "id", "jsonrpc", "result", "error":
+		default:
+			ext[k] = v
+		}
+	}
+	if len(ext) == 0 {
+		return b, nil
+	}
+	data, err := json.Marshal(ext)
+	if err != nil {
+		return nil, err
+	}
+	b = bytes.TrimSuffix(b, []byte("}"))
+	b = append(b, ',')
+	return append(b, data[1:]...), nil
+}
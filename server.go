@@ -4,7 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
 )
 
 const Version = "2.0"
@@ -14,6 +20,7 @@ const jsonRPCInvalidRequestError int = -32600
 const jsonRPCMethodNotFoundError int = -32601
 const jsonRPCInvalidParamsError int = -32602
 const jsonRPCInternalError int = -32603
+const jsonRPCServerError int = -32000
 
 type ErrorEncoder func(ctx context.Context, err error, w http.ResponseWriter)
 type BeforeFunc func(ctx context.Context, r *http.Request) (newCtx context.Context, err error)
@@ -28,32 +35,103 @@ type jsonRPCError struct {
 	Data    any    `json:"data,omitempty"`
 }
 
-type jsonRPCRequest struct {
-	ID      any             `json:"id"`
+type RPCID struct {
+	raw json.RawMessage
+	// invalid is set for an id that is not a string, number or null; the
+	// call is answered with an Invalid Request error carrying a null id.
+	invalid bool
+}
+
+func (id RPCID) MarshalJSON() ([]byte, error) {
+	if len(id.raw) == 0 {
+		return []byte("null"), nil
+	}
+	return id.raw, nil
+}
+
+func (id *RPCID) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 || !json.Valid(b) {
+		return errors.New("jsonrpc: invalid id")
+	}
+	switch c := b[0]; {
+	case c == '"', c == '-', c >= '0' && c <= '9', string(b) == "null":
+		id.invalid = false
+	default:
+		id.invalid = true
+	}
+	id.raw = append(id.raw[:0], b...)
+	return nil
+}
+
+// notification reports whether the request had no id member at all; an
+// explicit null id is still a call and gets a response.
+func (id RPCID) notification() bool {
+	return len(id.raw) == 0
+}
+
+func (id RPCID) String() string {
+	if len(id.raw) == 0 {
+		return "null"
+	}
+	return string(id.raw)
+}
+
+// Request is a single call as decoded by the server. RequestFromContext
+// returns it to before funcs, decoders, middleware and endpoints.
+type Request struct {
+	ID      RPCID           `json:"id"`
 	Version string          `json:"jsonrpc"`
 	Method  string          `json:"method"`
 	Params  json.RawMessage `json:"params"`
 }
 
 type jsonRPCResponse struct {
-	ID      any             `json:"id"`
+	ID      RPCID           `json:"id"`
 	Version string          `json:"jsonrpc"`
 	Error   *jsonRPCError   `json:"error,omitempty"`
 	Result  json.RawMessage `json:"result,omitempty"`
+
+	omit       bool
+	extensions map[string]any
+}
+
+// MarshalJSON guarantees exactly one of result and error is present.
+func (r jsonRPCResponse) MarshalJSON() ([]byte, error) {
+	type response jsonRPCResponse
+	if r.Error != nil {
+		r.Result = nil
+	} else if len(r.Result) == 0 {
+		r.Result = json.RawMessage("null")
+	}
+	b, err := json.Marshal(response(r))
+	if err != nil || len(r.extensions) == 0 {
+		return b, err
+	}
+	return appendExtensions(b, r.extensions)
 }
 
+type noResult struct{}
+
+// NoResult may be returned by an endpoint that has nothing to answer,
+// typically a notification-style method. Notifications get no response
+// either way; a call with an id still gets one, with a null result, as
+// JSON-RPC 2.0 requires.
+var NoResult any = noResult{}
+
 type jsonRPCRequestData struct {
-	requests []jsonRPCRequest
+	codec    Codec
+	requests []Request
 	isBatch  bool
 }
 
 func (r *jsonRPCRequestData) UnmarshalJSON(b []byte) error {
 	if bytes.HasPrefix(b, []byte("[")) {
 		r.isBatch = true
-		return json.Unmarshal(b, &r.requests)
+		return r.codec.Unmarshal(b, &r.requests)
 	}
-	var req jsonRPCRequest
-	if err := json.Unmarshal(b, &req); err != nil {
+	var req Request
+	if err := r.codec.Unmarshal(b, &req); err != nil {
 		return err
 	}
 	r.requests = append(r.requests, req)
@@ -62,119 +140,633 @@ func (r *jsonRPCRequestData) UnmarshalJSON(b []byte) error {
 
 type Option func(*Options)
 
+// Before registers funcs that run ahead of decoding. In a batch, the
+// context they return is passed on to the calls that follow.
 func Before(before ...BeforeFunc) Option {
 	return func(o *Options) {
 		o.before = append(o.before, before...)
 	}
 }
 
+// After registers funcs that run once the endpoint has returned
+// successfully and before anything is written, so headers they set on the
+// ResponseWriter end up in the response. In a batch they run per call,
+// all before the single response is encoded. They are skipped when the
+// call fails. ResponseFromContext gives them the encoded response.
 func After(after ...AfterFunc) Option {
 	return func(o *Options) {
 		o.after = append(o.after, after...)
 	}
 }
 
+type requestKey struct{}
+
+// RequestFromContext returns the call being handled.
+func RequestFromContext(ctx context.Context) (*Request, bool) {
+	req, ok := ctx.Value(requestKey{}).(*Request)
+	return req, ok
+}
+
+type responseKey struct{}
+
+// ResponseFromContext returns the response of the call to After funcs,
+// with the result already encoded. Changes an After func makes to Result
+// are sent; streamed results and subscription channels are not available.
+func ResponseFromContext(ctx context.Context) (*Response, bool) {
+	resp, ok := ctx.Value(responseKey{}).(*Response)
+	return resp, ok
+}
+
+// marshaledResult is a result encoded before the After funcs ran.
+type marshaledResult json.RawMessage
+
+type responseWriterKey struct{}
+
+// ResponseWriterFromContext returns the ResponseWriter of the HTTP request
+// being served, so endpoints can set headers and cookies. The body is
+// written by the server after all calls of a batch have returned, so
+// endpoints must not call Write or WriteHeader themselves.
+func ResponseWriterFromContext(ctx context.Context) (http.ResponseWriter, bool) {
+	w, ok := ctx.Value(responseWriterKey{}).(http.ResponseWriter)
+	return w, ok
+}
+
 func EndpointMiddleware(middleware ...EndpointMiddlewareFunc) Option {
 	return func(o *Options) {
 		o.middleware = append(o.middleware, middleware...)
 	}
 }
 
+// WithoutGlobalMiddleware, passed to Register, drops the server-wide
+// EndpointMiddleware for that method so only the method's own middleware
+// wraps its endpoint. Before, After and response middleware still apply.
+func WithoutGlobalMiddleware() Option {
+	return func(o *Options) {
+		o.withoutGlobalMiddleware = true
+	}
+}
+
+type InterceptFunc func(ctx context.Context, method string, params json.RawMessage, result json.RawMessage, err error)
+
+// WithAuditLog calls fn after every invocation of a registered method with
+// the params as received and the result as encoded, reusing the bytes the
+// server already has. On failure err is set and result is nil; streamed
+// results and NoResult returned to notifications are also reported as nil.
+func WithAuditLog(fn InterceptFunc) Option {
+	return func(o *Options) {
+		o.audit = append(o.audit, fn)
+	}
+}
+
+// WithErrorEncoder hands failures that happen before any method runs,
+// malformed or oversized bodies and too large batches, to enc instead of
+// the built-in handling. The error is a *Error carrying the JSON-RPC code
+// and wrapping the cause, such as an *http.MaxBytesError.
+func WithErrorEncoder(enc ErrorEncoder) Option {
+	return func(o *Options) {
+		o.errorEncoder = enc
+	}
+}
+
+// ErrorEncoderWithStatus returns an ErrorEncoder that writes the standard
+// JSON-RPC error body with the HTTP status chosen by status.
+func ErrorEncoderWithStatus(status func(err error) int) ErrorEncoder {
+	return func(ctx context.Context, err error, w http.ResponseWriter) {
+		resp := jsonRPCResponse{Version: Version, Error: &jsonRPCError{Code: jsonRPCInternalError, Message: err.Error()}}
+		var rpcErr *Error
+		if errors.As(err, &rpcErr) {
+			resp.Error = &jsonRPCError{Code: rpcErr.code, Message: rpcErr.message, Data: rpcErr.data}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(status(err))
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// WithErrorMapper translates errors returned by methods that are not
+// already a *Error. Returning nil falls back to an Internal Error with the
+// error's message.
+func WithErrorMapper(mapper func(error) *Error) Option {
+	return func(o *Options) {
+		o.errorMapper = mapper
+	}
+}
+
+// WithRequestValidator checks the raw params of every call before they are
+// decoded and answers Invalid Params, with the error message as data, when
+// v fails. It can be set server-wide or per Register call.
+func WithRequestValidator(v func(method string, params json.RawMessage) error) Option {
+	return func(o *Options) {
+		o.requestValidator = v
+	}
+}
+
+type MetricsObserver interface {
+	ObserveRequest(method string, code int, dur time.Duration)
+}
+
+func WithMetrics(observer MetricsObserver) Option {
+	return func(o *Options) {
+		o.metrics = observer
+	}
+}
+
+func WithRateLimit(rps float64, burst int) Option {
+	return func(o *Options) {
+		o.limiter = rate.NewLimiter(rate.Limit(rps), burst)
+	}
+}
+
+func WithCodec(codec Codec) Option {
+	return func(o *Options) {
+		o.codec = codec
+	}
+}
+
+func WithMaxRequestBytes(n int64) Option {
+	return func(o *Options) {
+		o.maxRequestBytes = n
+	}
+}
+
+// WithMaxBatchSize is the former name of WithBatchSizeLimit.
+//
+// Deprecated: use WithBatchSizeLimit.
+func WithMaxBatchSize(n int) Option {
+	return WithBatchSizeLimit(n)
+}
+
+// WithBatchSizeLimit rejects batches with more than max entries with a
+// single Invalid Request error. Single requests are not affected. The
+// default is DefaultBatchSizeLimit; a max of 0 leaves batches unlimited.
+func WithBatchSizeLimit(max int) Option {
+	return func(o *Options) {
+		o.maxBatchSize = max
+	}
+}
+
 type Options struct {
 	before     []BeforeFunc
 	after      []AfterFunc
 	middleware []EndpointMiddlewareFunc
+	response   []ResponseMiddlewareFunc
+	audit      []InterceptFunc
+	metrics    MetricsObserver
+	limiter    *rate.Limiter
+	codec      Codec
+
+	maxRequestBytes  int64
+	maxBatchSize     int
+	idempotencyCache IdempotencyCache
+	errorEncoder     ErrorEncoder
+	requestID        func() string
+	errorMapper      func(error) *Error
+	requestValidator func(method string, params json.RawMessage) error
+	json5            bool
+
+	withoutGlobalMiddleware bool
+	timeout                 time.Duration
+	stageTimings            bool
+	introspection           bool
+	auth                    []func(ctx context.Context, r *http.Request) error
+	authErrorCode           int
 }
 
 type ServerMethod struct {
+	name      string
 	endpoint  Endpoint
 	reqDecode ReqDecode
 	opts      *Options
+
+	description  string
+	paramSchema  json.RawMessage
+	resultSchema json.RawMessage
 }
 
 type Server struct {
 	methods map[string]*ServerMethod
 	opts    *Options
+
+	subscriptions map[string]*ServerMethod
+	subsMu        sync.Mutex
+	subs          map[string]context.CancelFunc
+
+	shutdownMu sync.Mutex
+	done       chan struct{}
+	inflight   sync.WaitGroup
+
+	streams       sync.WaitGroup
+	streamsCtx    context.Context
+	cancelStreams context.CancelFunc
+
+	idempotencyMu    sync.Mutex
+	idempotencyCalls map[string]chan struct{}
 }
 
-func (s *Server) makeErrorResponse(id any, code int, message string) jsonRPCResponse {
+func (s *Server) makeErrorResponse(id RPCID, code int, message string) jsonRPCResponse {
 	return jsonRPCResponse{ID: id, Version: Version, Error: &jsonRPCError{Code: code, Message: message}}
 }
 
-func (s *Server) handleMethod(method *ServerMethod, ctx context.Context, w http.ResponseWriter, r *http.Request, params json.RawMessage) (resp any, err error) {
+func (s *Server) makeErrorResponseFromError(id RPCID, err error) jsonRPCResponse {
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) {
+		if code, ok := sentinelCode(err); ok {
+			rpcErr = &Error{code: code, message: err.Error()}
+		} else if s.opts.errorMapper != nil {
+			rpcErr = s.opts.errorMapper(err)
+		}
+	}
+	if rpcErr != nil {
+		return jsonRPCResponse{ID: id, Version: Version, Error: &jsonRPCError{Code: rpcErr.code, Message: rpcErr.message, Data: rpcErr.data}}
+	}
+	return s.makeErrorResponse(id, jsonRPCInternalError, err.Error())
+}
+
+// handleMethod runs method and also returns the context produced by the
+// before funcs, which later calls of the same batch build on.
+func (s *Server) handleMethod(method *ServerMethod, ctx context.Context, w http.ResponseWriter, r *http.Request, req Request) (newCtx context.Context, resp any, err error) {
+	newCtx = ctx
+	ctx = context.WithValue(ctx, responseWriterKey{}, w)
+	ctx = context.WithValue(ctx, requestKey{}, &req)
+	params := req.Params
+	var timer *stageTimer
+	if method.opts.timeout > 0 && method.opts.stageTimings {
+		timer = &stageTimer{start: time.Now()}
+	}
+	if err = method.opts.authorize(ctx, r); err != nil {
+		return newCtx, nil, err
+	}
 	for _, before := range method.opts.before {
-		ctx, err = before(ctx, r)
+		beforeCtx, err := before(ctx, r)
 		if err != nil {
-			return
+			return newCtx, nil, err
+		}
+		ctx, newCtx = beforeCtx, beforeCtx
+	}
+	if timer != nil {
+		timer.before = time.Now()
+	}
+	if method.opts.timeout > 0 {
+		timeoutCtx, cancel := context.WithTimeout(ctx, method.opts.timeout)
+		defer cancel()
+		defer func() {
+			if err != nil {
+				err = s.timeoutError(timeoutCtx, timer, err)
+			}
+		}()
+		ctx = timeoutCtx
+	}
+	if validate := method.opts.requestValidator; validate != nil {
+		if err := validate(method.name, params); err != nil {
+			return newCtx, nil, NewError(jsonRPCInvalidParamsError, "invalid params", err.Error())
 		}
 	}
 	request, err := method.reqDecode(ctx, r, params)
 	if err != nil {
-		return nil, err
+		return newCtx, nil, err
+	}
+	endpoint := method.endpoint
+	if timer != nil {
+		timer.decode = time.Now()
+		timer.chainStart = timer.decode
+		endpoint = timer.wrap(endpoint)
+	}
+	response, err := middlewareChain(method.opts.middleware)(endpoint)(ctx, request)
+	if timer != nil {
+		timer.chainEnd = time.Now()
 	}
-	response, err := middlewareChain(method.opts.middleware)(method.endpoint)(ctx, request)
 	if err != nil {
-		return nil, err
+		return newCtx, nil, err
+	}
+	if len(method.opts.after) == 0 {
+		return newCtx, response, nil
+	}
+	var out *Response
+	switch response.(type) {
+	case io.Reader, <-chan any:
+	default:
+		out = &Response{ID: req.ID}
+		if response != NoResult {
+			if out.Result, err = s.marshalResult(response); err != nil {
+				return newCtx, nil, err
+			}
+		}
+		ctx = context.WithValue(ctx, responseKey{}, out)
 	}
 	for _, after := range method.opts.after {
 		ctx = after(ctx, w)
 	}
-	return response, nil
+	if out != nil && out.Result != nil {
+		response = marshaledResult(out.Result)
+	}
+	return newCtx, response, nil
 }
 
 func (s *Server) Register(method string, endpoint Endpoint, reqDecode ReqDecode, opts ...Option) *ServerMethod {
+	sm := s.newMethod(method, endpoint, reqDecode, opts)
+	s.methods[method] = sm
+	return sm
+}
+
+func (s *Server) newMethod(method string, endpoint Endpoint, reqDecode ReqDecode, opts []Option) *ServerMethod {
 	o := &Options{
-		before:     s.opts.before,
-		after:      s.opts.after,
-		middleware: s.opts.middleware,
+		before:     append([]BeforeFunc(nil), s.opts.before...),
+		after:      append([]AfterFunc(nil), s.opts.after...),
+		middleware: append([]EndpointMiddlewareFunc(nil), s.opts.middleware...),
+		response:   append([]ResponseMiddlewareFunc(nil), s.opts.response...),
+		audit:      append([]InterceptFunc(nil), s.opts.audit...),
+
+		requestValidator: s.opts.requestValidator,
+		timeout:          s.opts.timeout,
+		stageTimings:     s.opts.stageTimings,
+		auth:             append([]func(context.Context, *http.Request) error(nil), s.opts.auth...),
+		authErrorCode:    s.opts.authErrorCode,
 	}
 	for _, opt := range opts {
 		opt(o)
 	}
-	sm := &ServerMethod{opts: o, endpoint: endpoint, reqDecode: reqDecode}
-	s.methods[method] = sm
-	return sm
+	if o.withoutGlobalMiddleware {
+		o.middleware = o.middleware[len(s.opts.middleware):]
+	}
+	return &ServerMethod{name: method, opts: o, endpoint: endpoint, reqDecode: reqDecode}
+}
+
+// RegisterAlias makes alias dispatch to the method registered as
+// existing, sharing its endpoint, decoder and options.
+func (s *Server) RegisterAlias(existing, alias string) error {
+	sm, ok := s.methods[existing]
+	if !ok {
+		return errors.New("jsonrpc: method " + existing + " is not registered")
+	}
+	s.methods[alias] = sm
+	return nil
+}
+
+func (s *Server) marshalResult(result any) (json.RawMessage, error) {
+	if data, ok := result.(marshaledResult); ok {
+		return json.RawMessage(data), nil
+	}
+	if rd, ok := result.(io.Reader); ok {
+		if closer, ok := rd.(io.Closer); ok {
+			defer closer.Close()
+		}
+		return io.ReadAll(rd)
+	}
+	return s.opts.codec.Marshal(result)
+}
+
+// handleRequest dispatches a single request. When stream is set and the
+// endpoint returns an io.Reader, the reader is handed back unread so the
+// caller can copy it straight into the response; otherwise the result is
+// buffered into resp.Result.
+func (s *Server) handleRequest(ctx context.Context, w http.ResponseWriter, r *http.Request, req Request, stream bool) (resp jsonRPCResponse, body io.Reader, newCtx context.Context) {
+	if s.opts.metrics != nil {
+		defer func(start time.Time) {
+			var code int
+			if resp.Error != nil {
+				code = resp.Error.Code
+			}
+			s.opts.metrics.ObserveRequest(req.Method, code, time.Since(start))
+		}(time.Now())
+	}
+	middleware := s.opts.response
+	if method, ok := s.methods[req.Method]; ok {
+		middleware = method.opts.response
+	}
+	resp, body, newCtx = s.dispatch(ctx, w, r, req, stream)
+	if len(middleware) > 0 && body == nil && !resp.omit {
+		resp = s.applyResponseMiddleware(ctx, middleware, req.Method, resp)
+	}
+	return resp, body, newCtx
+}
+
+func (s *Server) dispatch(ctx context.Context, w http.ResponseWriter, r *http.Request, req Request, stream bool) (jsonRPCResponse, io.Reader, context.Context) {
+	if req.ID.invalid {
+		return s.makeErrorResponse(RPCID{}, jsonRPCInvalidRequestError, "id must be a string, number or null"), nil, ctx
+	}
+	if s.opts.limiter != nil && !s.opts.limiter.Allow() {
+		return s.makeErrorResponse(req.ID, jsonRPCServerError, "rate limit exceeded"), nil, ctx
+	}
+	method, ok := s.methods[req.Method]
+	if !ok {
+		return s.makeErrorResponse(req.ID, jsonRPCMethodNotFoundError, "method "+req.Method+" not found"), nil, ctx
+	}
+	var auditResult json.RawMessage
+	var auditErr error
+	if len(method.opts.audit) > 0 {
+		defer func() {
+			for _, audit := range method.opts.audit {
+				audit(ctx, req.Method, req.Params, auditResult, auditErr)
+			}
+		}()
+	}
+	ctx, result, err := s.handleMethod(method, ctx, w, r, req)
+	if err != nil {
+		auditErr = err
+		return s.makeErrorResponseFromError(req.ID, err), nil, ctx
+	}
+	if result == NoResult {
+		if req.ID.notification() {
+			return jsonRPCResponse{ID: req.ID, Version: Version, omit: true}, nil, ctx
+		}
+		result = nil
+	}
+	if rd, ok := result.(io.Reader); ok && stream {
+		return jsonRPCResponse{ID: req.ID, Version: Version}, rd, ctx
+	}
+	data, err := s.marshalResult(result)
+	if err != nil {
+		auditErr = err
+		return s.makeErrorResponse(req.ID, jsonRPCInternalError, err.Error()), nil, ctx
+	}
+	auditResult = data
+	return jsonRPCResponse{ID: req.ID, Version: Version, Result: data}, nil, ctx
+}
+
+func (s *Server) writeStream(w io.Writer, resp jsonRPCResponse, body io.Reader) error {
+	if closer, ok := body.(io.Closer); ok {
+		defer closer.Close()
+	}
+	id, err := s.opts.codec.Marshal(resp.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `{"id":`+string(id)+`,"jsonrpc":"`+Version+`","result":`); err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "}\n")
+	return err
+}
+
+func (s *Server) setHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.begin(false) {
+		s.writeUnavailable(w)
+		return
+	}
+	defer s.inflight.Done()
 	ctx := r.Context()
-	var requestData jsonRPCRequestData
+	if s.opts.requestID != nil {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" {
+			id = s.opts.requestID()
+		}
+		ctx = ContextWithRequestID(ctx, id)
+		r = r.WithContext(ctx)
+		w.Header().Set(RequestIDHeader, id)
+	}
+	requestData := jsonRPCRequestData{codec: s.opts.codec}
 	var responses []jsonRPCResponse
-	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
-		responses = append(responses, s.makeErrorResponse(nil, jsonRPCParseError, err.Error()))
+	status := http.StatusOK
+	var idempotencyKey string
+	var idempotent *idempotentCall
+	if s.opts.idempotencyCache != nil {
+		idempotencyKey = r.Header.Get(IdempotencyKeyHeader)
+	}
+	if s.opts.maxRequestBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.opts.maxRequestBytes)
+	}
+	raw, err := io.ReadAll(r.Body)
+	if err == nil {
+		// Keep the body readable for before funcs, e.g. VerifySignature.
+		r.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(raw)), nil
+		}
+		r.Body, _ = r.GetBody()
+		var body io.Reader = bytes.NewReader(raw)
+		if s.opts.json5 {
+			body = newJSON5Reader(body)
+		}
+		err = s.opts.codec.NewDecoder(body).Decode(&requestData)
+	}
+	if err != nil {
+		if s.opts.errorEncoder != nil {
+			s.opts.errorEncoder(ctx, &Error{code: jsonRPCParseError, message: err.Error(), err: err}, w)
+			return
+		}
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		requestData.isBatch = false
+		responses = append(responses, s.makeErrorResponse(RPCID{}, jsonRPCParseError, err.Error()))
+	} else if s.opts.maxBatchSize > 0 && requestData.isBatch && len(requestData.requests) > s.opts.maxBatchSize {
+		if s.opts.errorEncoder != nil {
+			s.opts.errorEncoder(ctx, &Error{code: jsonRPCInvalidRequestError, message: "batch too large", err: ErrBatchTooLarge}, w)
+			return
+		}
+		requestData.isBatch = false
+		responses = append(responses, s.makeErrorResponse(RPCID{}, jsonRPCInvalidRequestError, "batch too large"))
 	} else {
+		if idempotencyKey != "" {
+			var served bool
+			if idempotent, served = s.serveIdempotent(ctx, w, r, idempotencyKey, raw, requestData); served {
+				return
+			}
+			defer idempotent.release()
+		}
 		for _, req := range requestData.requests {
-			method, ok := s.methods[req.Method]
-			if !ok {
-				responses = append(responses, s.makeErrorResponse(req.ID, jsonRPCMethodNotFoundError, "method "+req.Method+" not found"))
+			if err := ctx.Err(); err != nil {
+				// The client has gone away; answer the rest without running them.
+				if !req.ID.notification() {
+					responses = append(responses, s.makeErrorResponse(req.ID, jsonRPCServerError, err.Error()))
+				}
 				continue
 			}
-			resp, err := s.handleMethod(method, ctx, w, r, req.Params)
-			if err != nil {
-				responses = append(responses, s.makeErrorResponse(req.ID, jsonRPCInternalError, err.Error()))
+			var resp jsonRPCResponse
+			var body io.Reader
+			resp, body, ctx = s.handleRequest(ctx, w, r, req, !requestData.isBatch)
+			if req.ID.notification() {
+				if closer, ok := body.(io.Closer); ok {
+					_ = closer.Close()
+				}
 				continue
 			}
-			result, err := json.Marshal(resp)
-			if err != nil {
-				responses = append(responses, s.makeErrorResponse(req.ID, jsonRPCInternalError, err.Error()))
-				continue
+			if body != nil {
+				s.setHeaders(w)
+				// A reader failing halfway leaves the response truncated,
+				// which the client sees as a parse error.
+				_ = s.writeStream(w, resp, body)
+				return
+			}
+			if !resp.omit {
+				responses = append(responses, resp)
 			}
-			responses = append(responses, jsonRPCResponse{ID: req.ID, Version: "2.0", Result: result})
 		}
 	}
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 	var data any
 	if requestData.isBatch {
 		data = responses
 	} else {
 		data = responses[0]
 	}
-	_ = json.NewEncoder(w).Encode(data)
+	var buf bytes.Buffer
+	if err := s.opts.codec.NewEncoder(&buf).Encode(data); err != nil {
+		s.writeErrorResponses(w, http.StatusOK, requestData, NewError(jsonRPCInternalError, "encoding response failed", err.Error()))
+		return
+	}
+	s.setHeaders(w)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+	}
+	if idempotent != nil && status == http.StatusOK {
+		idempotent.store(s.opts.idempotencyCache, buf.Bytes())
+	}
+	_, _ = w.Write(buf.Bytes())
 }
 
+// DefaultBatchSizeLimit is the largest batch a server accepts unless
+// WithBatchSizeLimit says otherwise.
+const DefaultBatchSizeLimit = 100
+
 func NewServer(opts ...Option) *Server {
-	o := &Options{}
+	o := &Options{maxBatchSize: DefaultBatchSizeLimit}
 	for _, opt := range opts {
 		opt(o)
 	}
-	return &Server{methods: make(map[string]*ServerMethod, 128), opts: o}
+	if o.codec == nil {
+		o.codec = stdCodec{}
+	}
+	s := &Server{methods: make(map[string]*ServerMethod, 128), opts: o, done: make(chan struct{})}
+	s.streamsCtx, s.cancelStreams = context.WithCancel(context.Background())
+	if o.introspection {
+		s.Register(ListMethodsMethod, s.listMethods, noParamsDecode)
+	}
+	return s
+}
+
+// RegisterFunc registers fn under method, decoding params into a new Req
+// with the server's codec. Absent or null params leave Req zero valued.
+// Go does not allow type parameters on methods, hence the s argument.
+func RegisterFunc[Req any, Resp any](s Registrar, method string, fn func(ctx context.Context, req *Req) (*Resp, error), opts ...Option) *ServerMethod {
+	decode := func(ctx context.Context, r *http.Request, params json.RawMessage) (any, error) {
+		req := new(Req)
+		if len(params) == 0 || string(params) == "null" {
+			return req, nil
+		}
+		if err := s.serverCodec().Unmarshal(params, req); err != nil {
+			return nil, NewError(jsonRPCInvalidParamsError, err.Error(), nil)
+		}
+		return req, nil
+	}
+	endpoint := func(ctx context.Context, request interface{}) (interface{}, error) {
+		resp, err := fn(ctx, request.(*Req))
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+	return s.Register(method, endpoint, decode, opts...)
 }
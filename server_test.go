@@ -1,9 +1,1092 @@
 package jsonrpc_test
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/555f/jsonrpc"
 )
 
+type testResponse struct {
+	ID     any             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Data    any    `json:"data"`
+	} `json:"error"`
+}
+
+func rawDecode(ctx context.Context, r *http.Request, params json.RawMessage) (any, error) {
+	return params, nil
+}
+
+func echoEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	return request, nil
+}
+
+func serve(t *testing.T, s *jsonrpc.Server, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	return w
+}
+
+func serveBatch(t *testing.T, s *jsonrpc.Server, body string) []testResponse {
+	t.Helper()
+	var responses []testResponse
+	if err := json.Unmarshal(serve(t, s, body).Body.Bytes(), &responses); err != nil {
+		t.Fatal(err)
+	}
+	return responses
+}
+
 func TestServer(t *testing.T) {
 
 }
+
+func TestServerRateLimit(t *testing.T) {
+	s := jsonrpc.NewServer(jsonrpc.WithRateLimit(0.001, 2))
+	s.Register("echo", echoEndpoint, rawDecode)
+	responses := serveBatch(t, s, `[
+		{"id":1,"jsonrpc":"2.0","method":"echo","params":1},
+		{"id":2,"jsonrpc":"2.0","method":"echo","params":2},
+		{"id":3,"jsonrpc":"2.0","method":"echo","params":3}
+	]`)
+	if len(responses) != 3 {
+		t.Fatalf("got %d responses, want 3", len(responses))
+	}
+	for _, resp := range responses[:2] {
+		if resp.Error != nil {
+			t.Fatalf("unexpected error %v", resp.Error.Message)
+		}
+	}
+	if resp := responses[2]; resp.Error == nil || resp.Error.Code != -32000 {
+		t.Fatalf("want rate limit error, got %+v", resp)
+	}
+}
+
+func largeArray(n int) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		w := bufio.NewWriter(pw)
+		w.WriteString("[")
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				w.WriteString(",")
+			}
+			w.WriteString(strconv.Itoa(i))
+		}
+		w.WriteString("]")
+		pw.CloseWithError(w.Flush())
+	}()
+	return pr
+}
+
+func TestServerStreamResult(t *testing.T) {
+	const n = 500000
+	s := jsonrpc.NewServer()
+	s.Register("large", func(ctx context.Context, request interface{}) (interface{}, error) {
+		return largeArray(n), nil
+	}, rawDecode)
+
+	w := serve(t, s, `{"id":7,"jsonrpc":"2.0","method":"large"}`)
+	if w.Body.Len() < 1<<20 {
+		t.Fatalf("response is only %d bytes", w.Body.Len())
+	}
+	var resp struct {
+		ID     int   `json:"id"`
+		Result []int `json:"result"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != 7 || len(resp.Result) != n || resp.Result[n-1] != n-1 {
+		t.Fatalf("unexpected streamed response: id=%d len=%d", resp.ID, len(resp.Result))
+	}
+
+	responses := serveBatch(t, s, `[{"id":1,"jsonrpc":"2.0","method":"large"},{"id":2,"jsonrpc":"2.0","method":"large"}]`)
+	if len(responses) != 2 {
+		t.Fatalf("got %d responses, want 2", len(responses))
+	}
+	for i, resp := range responses {
+		if resp.ID != float64(i+1) {
+			t.Fatalf("response %d has id %v, want %d", i, resp.ID, i+1)
+		}
+		var result []int
+		if err := json.Unmarshal(resp.Result, &result); err != nil {
+			t.Fatal(err)
+		}
+		if len(result) != n {
+			t.Fatalf("batch result has %d elements, want %d", len(result), n)
+		}
+	}
+}
+
+func TestServerBeforeFuncError(t *testing.T) {
+	unauthorized := func(ctx context.Context, r *http.Request) (context.Context, error) {
+		if r.Header.Get("Authorization") == "" {
+			return ctx, jsonrpc.NewError(-32001, "unauthorized", "missing token")
+		}
+		return ctx, nil
+	}
+	s := jsonrpc.NewServer(jsonrpc.Before(unauthorized))
+	s.Register("echo", echoEndpoint, rawDecode)
+	responses := serveBatch(t, s, `[{"id":1,"jsonrpc":"2.0","method":"echo","params":1}]`)
+	resp := responses[0]
+	if resp.Error == nil || resp.Error.Code != -32001 || resp.Error.Message != "unauthorized" || resp.Error.Data != "missing token" {
+		t.Fatalf("unexpected response %+v", resp.Error)
+	}
+}
+
+func TestServerIDRoundTrip(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	for _, id := range []string{`100`, `12345678901234567890123`, `"abc"`, `null`, `-1.5`} {
+		body := serve(t, s, `{"id":`+id+`,"jsonrpc":"2.0","method":"echo","params":1}`).Body.String()
+		if !strings.HasPrefix(body, `{"id":`+id+`,`) {
+			t.Errorf("id %s came back as %s", id, body)
+		}
+	}
+	responses := serveBatch(t, s, `[{"id":{},"jsonrpc":"2.0","method":"echo"},{"id":true,"jsonrpc":"2.0","method":"echo"},{"id":1,"jsonrpc":"2.0","method":"echo","params":1}]`)
+	if len(responses) != 3 {
+		t.Fatalf("got %d responses, want 3", len(responses))
+	}
+	for i, resp := range responses[:2] {
+		if resp.Error == nil || resp.Error.Code != -32600 || resp.ID != nil {
+			t.Fatalf("invalid id %d was accepted: %+v", i, resp)
+		}
+	}
+	if responses[2].Error != nil || string(responses[2].Result) != "1" {
+		t.Fatalf("valid call was not answered: %+v", responses[2])
+	}
+}
+
+func TestServerContentType(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	w := serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"echo","params":1}`)
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Fatalf("got Content-Type %q, want application/json", got)
+	}
+	if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Fatalf("got X-Content-Type-Options %q, want nosniff", got)
+	}
+}
+
+func TestServerNilResult(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("nil", func(ctx context.Context, request interface{}) (interface{}, error) {
+		return nil, nil
+	}, rawDecode)
+	s.Register("raw", func(ctx context.Context, request interface{}) (interface{}, error) {
+		return json.RawMessage(nil), nil
+	}, rawDecode)
+	s.Register("notify", func(ctx context.Context, request interface{}) (interface{}, error) {
+		return jsonrpc.NoResult, nil
+	}, rawDecode)
+
+	for _, method := range []string{"nil", "raw"} {
+		body := serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"`+method+`"}`).Body.String()
+		if body != `{"id":1,"jsonrpc":"2.0","result":null}`+"\n" {
+			t.Errorf("%s: unexpected response %s", method, body)
+		}
+	}
+
+	responses := serveBatch(t, s, `[{"id":1,"jsonrpc":"2.0","method":"notify"},{"jsonrpc":"2.0","method":"notify"},{"id":2,"jsonrpc":"2.0","method":"nil"}]`)
+	if len(responses) != 2 || responses[0].ID != float64(1) || string(responses[0].Result) != "null" || responses[1].ID != float64(2) {
+		t.Fatalf("NoResult call was not answered with a null result: %+v", responses)
+	}
+	w := serve(t, s, `{"jsonrpc":"2.0","method":"notify"}`)
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Fatalf("got %d %q, want empty 204", w.Code, w.Body.String())
+	}
+}
+
+func TestServerRequestLimits(t *testing.T) {
+	s := jsonrpc.NewServer(jsonrpc.WithMaxRequestBytes(200), jsonrpc.WithMaxBatchSize(2))
+	s.Register("echo", echoEndpoint, rawDecode)
+
+	w := serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"echo","params":"`+strings.Repeat("x", 300)+`"}`)
+	var resp testResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusRequestEntityTooLarge || resp.Error == nil || resp.Error.Code != -32700 {
+		t.Fatalf("oversized body: got %d %s", w.Code, w.Body.String())
+	}
+
+	w = serve(t, s, `[{"id":1,"jsonrpc":"2.0","method":"echo"},{"id":2,"jsonrpc":"2.0","method":"echo"},{"id":3,"jsonrpc":"2.0","method":"echo"}]`)
+	resp = testResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("want a single error object, got %s", w.Body.String())
+	}
+	if resp.Error == nil || resp.Error.Code != -32600 {
+		t.Fatalf("oversized batch: got %s", w.Body.String())
+	}
+
+	if responses := serveBatch(t, s, `[{"id":1,"jsonrpc":"2.0","method":"echo"},{"id":2,"jsonrpc":"2.0","method":"echo"}]`); len(responses) != 2 {
+		t.Fatalf("got %d responses, want 2", len(responses))
+	}
+}
+
+func TestServerDefaultBatchSizeLimit(t *testing.T) {
+	calls := make([]string, jsonrpc.DefaultBatchSizeLimit+1)
+	for i := range calls {
+		calls[i] = `{"id":` + strconv.Itoa(i) + `,"jsonrpc":"2.0","method":"echo"}`
+	}
+	batch := "[" + strings.Join(calls, ",") + "]"
+
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	var resp testResponse
+	if err := json.Unmarshal(serve(t, s, batch).Body.Bytes(), &resp); err != nil || resp.Error == nil || resp.Error.Code != -32600 {
+		t.Fatalf("batch over the default limit was not rejected: %+v, %v", resp, err)
+	}
+
+	s = jsonrpc.NewServer(jsonrpc.WithBatchSizeLimit(0))
+	s.Register("echo", echoEndpoint, rawDecode)
+	if responses := serveBatch(t, s, batch); len(responses) != len(calls) {
+		t.Fatalf("got %d responses, want %d", len(responses), len(calls))
+	}
+}
+
+func TestServerIdempotencyCache(t *testing.T) {
+	var calls int
+	s := jsonrpc.NewServer(jsonrpc.WithIdempotencyCache(jsonrpc.NewInMemoryIdempotencyCache(time.Minute)))
+	s.Register("charge", func(ctx context.Context, request interface{}) (interface{}, error) {
+		calls++
+		return calls, nil
+	}, rawDecode)
+	var keys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(jsonrpc.IdempotencyKeyHeader))
+		s.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	retry := func(next jsonrpc.RoundTripper) jsonrpc.RoundTripper {
+		return func(ctx context.Context, payload []byte) ([]byte, error) {
+			if _, err := next(ctx, payload); err != nil {
+				return nil, err
+			}
+			return next(ctx, payload)
+		}
+	}
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithIdempotencyKey(), jsonrpc.WithRoundTripMiddleware(retry))
+	for i := 1; i <= 2; i++ {
+		result, err := c.Execute(&echoRequest{method: "charge"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := result.At(0); got != float64(i) {
+			t.Fatalf("call %d got result %v", i, got)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("endpoint ran %d times, want 2", calls)
+	}
+	if len(keys) != 4 || keys[0] == "" || keys[0] != keys[1] || keys[2] != keys[3] || keys[1] == keys[2] {
+		t.Fatalf("unexpected keys %q", keys)
+	}
+}
+
+func TestServerIdempotencyCacheBinding(t *testing.T) {
+	var calls int64
+	release := make(chan struct{})
+	s := jsonrpc.NewServer(
+		jsonrpc.WithIdempotencyCache(jsonrpc.NewInMemoryIdempotencyCache(time.Minute)),
+		jsonrpc.WithAuthMiddleware(func(ctx context.Context, r *http.Request) error {
+			if r.Header.Get("Authorization") == "" {
+				return errors.New("no credentials")
+			}
+			return nil
+		}),
+	)
+	s.Register("charge", func(ctx context.Context, request interface{}) (interface{}, error) {
+		<-release
+		return atomic.AddInt64(&calls, 1), nil
+	}, rawDecode)
+	post := func(auth, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set(jsonrpc.IdempotencyKeyHeader, "k")
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+	body := `{"id":1,"jsonrpc":"2.0","method":"charge"}`
+
+	var wg sync.WaitGroup
+	responses := make([]string, 3)
+	for i := range responses {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i] = post("alice", body).Body.String()
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls != 1 || responses[0] != responses[1] || responses[1] != responses[2] {
+		t.Fatalf("endpoint ran %d times, responses %q", calls, responses)
+	}
+
+	if w := post("alice", `{"id":2,"jsonrpc":"2.0","method":"charge"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("reused key with another body got status %d", w.Code)
+	}
+	if w := post("", body); !strings.Contains(w.Body.String(), "unauthorized") {
+		t.Fatalf("replay without credentials got %s", w.Body)
+	}
+	post("bob", body)
+	if calls != 2 {
+		t.Fatalf("another caller's request was answered from the cache")
+	}
+}
+
+func TestServerEncodeError(t *testing.T) {
+	s := jsonrpc.NewServer(jsonrpc.ResponseMiddleware(func(ctx context.Context, method string, resp *jsonrpc.Response) {
+		resp.Extensions = map[string]any{"bad": func() {}}
+	}))
+	s.Register("echo", echoEndpoint, rawDecode)
+	var resp testResponse
+	if err := json.Unmarshal(serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"echo","params":1}`).Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil || resp.Error.Code != -32603 || resp.ID != float64(1) {
+		t.Fatalf("got %+v, want an internal error for the unencodable response", resp)
+	}
+}
+
+func TestServerResponseMiddleware(t *testing.T) {
+	var order []string
+	tag := func(name string) jsonrpc.EndpointMiddlewareFunc {
+		return func(next jsonrpc.Endpoint) jsonrpc.Endpoint {
+			return func(ctx context.Context, request interface{}) (interface{}, error) {
+				order = append(order, name)
+				return next(ctx, request)
+			}
+		}
+	}
+	timing := func(ctx context.Context, method string, resp *jsonrpc.Response) {
+		order = append(order, "response")
+		resp.Extensions = map[string]any{"method": method, "id": "ignored"}
+		if resp.Error != nil {
+			resp.Error = jsonrpc.NewError(-32099, "rewritten", nil)
+		}
+	}
+	s := jsonrpc.NewServer(jsonrpc.EndpointMiddleware(tag("endpoint")), jsonrpc.ResponseMiddleware(timing))
+	s.Register("echo", echoEndpoint, rawDecode)
+
+	body := serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"echo","params":2}`).Body.String()
+	if body != `{"id":1,"jsonrpc":"2.0","result":2,"method":"echo"}`+"\n" {
+		t.Fatalf("unexpected response %s", body)
+	}
+	if strings.Join(order, ",") != "endpoint,response" {
+		t.Fatalf("unexpected order %v", order)
+	}
+	var resp testResponse
+	if err := json.Unmarshal(serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"missing"}`).Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil || resp.Error.Code != -32099 {
+		t.Fatalf("error was not rewritten: %+v", resp)
+	}
+}
+
+type transferParams struct {
+	From   string `json:"from" validate:"required"`
+	To     string `json:"to" validate:"required"`
+	Amount int    `json:"amount"`
+}
+
+// validateRequired stands in for a real struct-tag validator.
+func validateRequired(v any) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	var errs []error
+	for i := 0; i < rv.NumField(); i++ {
+		f := rv.Type().Field(i)
+		if f.Tag.Get("validate") == "required" && rv.Field(i).IsZero() {
+			errs = append(errs, errors.New(f.Tag.Get("json")+" is required"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func TestServerValidateParams(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("transfer", echoEndpoint, func(ctx context.Context, r *http.Request, params json.RawMessage) (any, error) {
+		var p transferParams
+		err := json.Unmarshal(params, &p)
+		return &p, err
+	}, jsonrpc.EndpointMiddleware(jsonrpc.ValidateParams(validateRequired)))
+
+	var resp testResponse
+	if err := json.Unmarshal(serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"transfer","params":{"amount":5}}`).Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Fatalf("want invalid params, got %+v", resp)
+	}
+	if details, _ := resp.Error.Data.([]any); len(details) != 2 || details[0] != "from is required" {
+		t.Fatalf("unexpected error data %v", resp.Error.Data)
+	}
+	resp = testResponse{}
+	if err := json.Unmarshal(serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"transfer","params":{"from":"a","to":"b"}}`).Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != nil {
+		t.Fatalf("valid params rejected: %+v", resp.Error)
+	}
+}
+
+func TestServerMiddlewareOrder(t *testing.T) {
+	var order []string
+	tag := func(name string) jsonrpc.EndpointMiddlewareFunc {
+		return func(next jsonrpc.Endpoint) jsonrpc.Endpoint {
+			return func(ctx context.Context, request interface{}) (interface{}, error) {
+				order = append(order, name+">")
+				defer func() { order = append(order, "<"+name) }()
+				return next(ctx, request)
+			}
+		}
+	}
+	s := jsonrpc.NewServer(jsonrpc.EndpointMiddleware(tag("A"), tag("B"), tag("C")))
+	s.Register("echo", func(ctx context.Context, request interface{}) (interface{}, error) {
+		order = append(order, "endpoint")
+		return request, nil
+	}, rawDecode)
+	serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"echo"}`)
+	if got := strings.Join(order, " "); got != "A> B> C> endpoint <C <B <A" {
+		t.Fatalf("got call order %q", got)
+	}
+}
+
+func TestServerWithoutGlobalMiddleware(t *testing.T) {
+	var seen []string
+	tag := func(name string) jsonrpc.EndpointMiddlewareFunc {
+		return func(next jsonrpc.Endpoint) jsonrpc.Endpoint {
+			return func(ctx context.Context, request interface{}) (interface{}, error) {
+				seen = append(seen, name)
+				return next(ctx, request)
+			}
+		}
+	}
+	s := jsonrpc.NewServer(jsonrpc.EndpointMiddleware(tag("global")))
+	s.Register("plain", echoEndpoint, rawDecode, jsonrpc.EndpointMiddleware(tag("method")))
+	s.Register("health", echoEndpoint, rawDecode, jsonrpc.EndpointMiddleware(tag("method")), jsonrpc.WithoutGlobalMiddleware())
+
+	serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"plain"}`)
+	serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"health"}`)
+	if got := strings.Join(seen, ","); got != "global,method,method" {
+		t.Fatalf("middleware ran as %q", got)
+	}
+}
+
+func TestServerRateLimitMiddleware(t *testing.T) {
+	byToken := func(ctx context.Context, r *http.Request) (context.Context, error) {
+		return jsonrpc.ContextWithRateLimitKey(ctx, r.Header.Get("X-Token")), nil
+	}
+	s := jsonrpc.NewServer(jsonrpc.Before(byToken))
+	s.Register("echo", echoEndpoint, rawDecode, jsonrpc.EndpointMiddleware(jsonrpc.RateLimit(jsonrpc.NewKeyedLimiter(0.001, 2))))
+
+	call := func(token string) *testResponse {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"id":1,"jsonrpc":"2.0","method":"echo"}`))
+		r.Header.Set("X-Token", token)
+		s.ServeHTTP(w, r)
+		var resp testResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return &resp
+	}
+	for i := 0; i < 2; i++ {
+		if resp := call("a"); resp.Error != nil {
+			t.Fatalf("call %d within burst failed: %+v", i, resp.Error)
+		}
+	}
+	if resp := call("a"); resp.Error == nil || resp.Error.Code != -32000 {
+		t.Fatalf("want rate limit error, got %+v", resp)
+	}
+	if resp := call("b"); resp.Error != nil {
+		t.Fatalf("other key was limited: %+v", resp.Error)
+	}
+}
+
+type addParams struct {
+	A, B int
+}
+
+type addResult struct {
+	Sum int `json:"sum"`
+}
+
+func TestRegisterFunc(t *testing.T) {
+	s := jsonrpc.NewServer()
+	jsonrpc.RegisterFunc(s, "add", func(ctx context.Context, req *addParams) (*addResult, error) {
+		return &addResult{Sum: req.A + req.B}, nil
+	})
+	if body := serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"add","params":{"A":2,"B":3}}`).Body.String(); body != `{"id":1,"jsonrpc":"2.0","result":{"sum":5}}`+"\n" {
+		t.Fatalf("unexpected response %s", body)
+	}
+	var resp testResponse
+	if err := json.Unmarshal(serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"add","params":"x"}`).Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Fatalf("want invalid params, got %+v", resp)
+	}
+}
+
+type principalKey struct{}
+
+func TestServerAuthBefore(t *testing.T) {
+	verify := func(ctx context.Context, token string) (context.Context, error) {
+		if token != "secret" {
+			return ctx, errors.New("bad token")
+		}
+		return context.WithValue(ctx, principalKey{}, "alice"), nil
+	}
+	s := jsonrpc.NewServer(jsonrpc.Before(jsonrpc.AuthBefore(verify)))
+	s.Register("whoami", func(ctx context.Context, request interface{}) (interface{}, error) {
+		return ctx.Value(principalKey{}), nil
+	}, rawDecode)
+
+	call := func(auth string) testResponse {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"id":1,"jsonrpc":"2.0","method":"whoami"}`))
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		s.ServeHTTP(w, r)
+		var resp testResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	if resp := call("Bearer secret"); resp.Error != nil || string(resp.Result) != `"alice"` {
+		t.Fatalf("valid token: %+v %s", resp.Error, resp.Result)
+	}
+	for _, auth := range []string{"", "Basic secret", "Bearer wrong"} {
+		if resp := call(auth); resp.Error == nil || resp.Error.Code != jsonrpc.ErrorCodeUnauthorized {
+			t.Fatalf("%q: want unauthorized, got %+v", auth, resp)
+		}
+	}
+}
+
+func TestServerAuditLog(t *testing.T) {
+	var entries []string
+	audit := func(ctx context.Context, method string, params, result json.RawMessage, err error) {
+		entries = append(entries, fmt.Sprintf("%s %s %q %v", method, params, string(result), err))
+	}
+	s := jsonrpc.NewServer(jsonrpc.WithAuditLog(audit))
+	s.Register("echo", echoEndpoint, rawDecode)
+	s.Register("fail", func(ctx context.Context, request interface{}) (interface{}, error) {
+		return nil, errors.New("boom")
+	}, rawDecode)
+	serveBatch(t, s, `[{"id":1,"jsonrpc":"2.0","method":"echo","params":[1]},{"id":2,"jsonrpc":"2.0","method":"fail","params":{"a":1}}]`)
+	if len(entries) != 2 || entries[0] != `echo [1] "[1]" <nil>` || entries[1] != `fail {"a":1} "" boom` {
+		t.Fatalf("unexpected audit entries %q", entries)
+	}
+}
+
+func TestServerErrorEncoder(t *testing.T) {
+	status := func(err error) int {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return http.StatusRequestEntityTooLarge
+		}
+		return http.StatusBadRequest
+	}
+	s := jsonrpc.NewServer(jsonrpc.WithMaxRequestBytes(100), jsonrpc.WithBatchSizeLimit(1), jsonrpc.WithErrorEncoder(jsonrpc.ErrorEncoderWithStatus(status)))
+	s.Register("echo", echoEndpoint, rawDecode)
+
+	for _, tc := range []struct {
+		body   string
+		status int
+		code   int
+	}{
+		{`{"id":1,`, http.StatusBadRequest, -32700},
+		{`{"id":1,"jsonrpc":"2.0","method":"echo","params":"` + strings.Repeat("x", 200) + `"}`, http.StatusRequestEntityTooLarge, -32700},
+		{`[{"id":1,"jsonrpc":"2.0","method":"echo"},{"id":2,"jsonrpc":"2.0","method":"echo"}]`, http.StatusBadRequest, -32600},
+		{`{"id":1,"jsonrpc":"2.0","method":"echo"}`, http.StatusOK, 0},
+	} {
+		w := serve(t, s, tc.body)
+		var resp testResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		var code int
+		if resp.Error != nil {
+			code = resp.Error.Code
+		}
+		if w.Code != tc.status || code != tc.code {
+			t.Errorf("%.20s: got %d/%d, want %d/%d", tc.body, w.Code, code, tc.status, tc.code)
+		}
+	}
+}
+
+func TestServerGroup(t *testing.T) {
+	var seen []string
+	tag := func(name string) jsonrpc.EndpointMiddlewareFunc {
+		return func(next jsonrpc.Endpoint) jsonrpc.Endpoint {
+			return func(ctx context.Context, request interface{}) (interface{}, error) {
+				seen = append(seen, name)
+				return next(ctx, request)
+			}
+		}
+	}
+	s := jsonrpc.NewServer(jsonrpc.EndpointMiddleware(tag("server")))
+	users := s.Group("user", jsonrpc.EndpointMiddleware(tag("group")))
+	users.Register("get", echoEndpoint, rawDecode, jsonrpc.EndpointMiddleware(tag("method")))
+	jsonrpc.RegisterFunc(users, "add", func(ctx context.Context, req *addParams) (*addResult, error) {
+		return &addResult{Sum: req.A + req.B}, nil
+	})
+	s.Register("ping", echoEndpoint, rawDecode)
+
+	if body := serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"user.get","params":1}`).Body.String(); !strings.Contains(body, `"result":1`) {
+		t.Fatalf("unexpected response %s", body)
+	}
+	if got := strings.Join(seen, ","); got != "server,group,method" {
+		t.Fatalf("middleware ran as %q", got)
+	}
+	if body := serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"user.add","params":{"A":1,"B":2}}`).Body.String(); !strings.Contains(body, `"sum":3`) {
+		t.Fatalf("unexpected response %s", body)
+	}
+	if body := serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"get"}`).Body.String(); !strings.Contains(body, "-32601") {
+		t.Fatalf("unprefixed name resolved: %s", body)
+	}
+}
+
+func TestServerAfterFuncHeader(t *testing.T) {
+	after := func(ctx context.Context, w http.ResponseWriter) context.Context {
+		w.Header().Set("X-Served-By", "echo")
+		return ctx
+	}
+	s := jsonrpc.NewServer(jsonrpc.After(after))
+	s.Register("echo", echoEndpoint, rawDecode)
+	w := serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"echo","params":1}`)
+	if got := w.Header().Get("X-Served-By"); got != "echo" {
+		t.Fatalf("got X-Served-By %q, want echo", got)
+	}
+}
+
+func TestResponseWriterFromContext(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("login", func(ctx context.Context, request interface{}) (interface{}, error) {
+		w, ok := jsonrpc.ResponseWriterFromContext(ctx)
+		if !ok {
+			return nil, errors.New("no ResponseWriter")
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1"})
+		return true, nil
+	}, rawDecode)
+	w := serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"login"}`)
+	if got := w.Header().Get("Set-Cookie"); got != "session=s1" || !strings.Contains(w.Body.String(), `"result":true`) {
+		t.Fatalf("got Set-Cookie %q, body %s", got, w.Body.String())
+	}
+}
+
+func TestServerSentinelErrors(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("strict", echoEndpoint, func(ctx context.Context, r *http.Request, params json.RawMessage) (any, error) {
+		return nil, fmt.Errorf("%w: name is required", jsonrpc.ErrInvalidParams)
+	})
+	s.Register("gone", func(ctx context.Context, request interface{}) (interface{}, error) {
+		return nil, jsonrpc.ErrMethodNotFound
+	}, rawDecode)
+	responses := serveBatch(t, s, `[{"id":1,"jsonrpc":"2.0","method":"strict"},{"id":2,"jsonrpc":"2.0","method":"gone"}]`)
+	if e := responses[0].Error; e == nil || e.Code != -32602 || e.Message != "invalid params: name is required" {
+		t.Fatalf("unexpected error %+v", e)
+	}
+	if e := responses[1].Error; e == nil || e.Code != -32601 {
+		t.Fatalf("unexpected error %+v", e)
+	}
+}
+
+func TestRequestFromContext(t *testing.T) {
+	authorize := func(ctx context.Context, r *http.Request) (context.Context, error) {
+		if req, ok := jsonrpc.RequestFromContext(ctx); !ok || strings.HasPrefix(req.Method, "admin.") {
+			return ctx, jsonrpc.NewError(-32001, "forbidden", nil)
+		}
+		return ctx, nil
+	}
+	s := jsonrpc.NewServer(jsonrpc.Before(authorize))
+	s.Register("echo", echoEndpoint, rawDecode)
+	s.Register("admin.reset", echoEndpoint, rawDecode)
+	responses := serveBatch(t, s, `[{"id":1,"jsonrpc":"2.0","method":"echo","params":1},{"id":2,"jsonrpc":"2.0","method":"admin.reset"}]`)
+	if responses[0].Error != nil || responses[1].Error == nil || responses[1].Error.Code != -32001 {
+		t.Fatalf("unexpected responses %+v", responses)
+	}
+}
+
+func TestResponseFromContext(t *testing.T) {
+	var seen []string
+	after := func(ctx context.Context, w http.ResponseWriter) context.Context {
+		req, _ := jsonrpc.RequestFromContext(ctx)
+		resp, ok := jsonrpc.ResponseFromContext(ctx)
+		if ok {
+			seen = append(seen, req.Method+"="+string(resp.Result))
+			resp.Result = json.RawMessage(`"rewritten"`)
+		}
+		return ctx
+	}
+	s := jsonrpc.NewServer(jsonrpc.After(after))
+	s.Register("echo", echoEndpoint, rawDecode)
+	responses := serveBatch(t, s, `[{"id":1,"jsonrpc":"2.0","method":"echo","params":1},{"id":2,"jsonrpc":"2.0","method":"echo","params":"b"}]`)
+	if len(seen) != 2 || seen[0] != "echo=1" || seen[1] != `echo="b"` {
+		t.Fatalf("after funcs saw %q", seen)
+	}
+	for _, resp := range responses {
+		if string(resp.Result) != `"rewritten"` {
+			t.Fatalf("result rewritten by the after func was not sent: %s", resp.Result)
+		}
+	}
+}
+
+func TestServerErrorMapper(t *testing.T) {
+	errNotFound := errors.New("not found")
+	mapper := func(err error) *jsonrpc.Error {
+		if errors.Is(err, errNotFound) {
+			return jsonrpc.NewError(-32004, "resource not found", nil)
+		}
+		return nil
+	}
+	s := jsonrpc.NewServer(jsonrpc.WithErrorMapper(mapper))
+	for name, err := range map[string]error{
+		"missing": fmt.Errorf("user 7: %w", errNotFound),
+		"typed":   jsonrpc.NewError(-32010, "typed", nil),
+		"other":   errors.New("boom"),
+	} {
+		err := err
+		s.Register(name, func(ctx context.Context, request interface{}) (interface{}, error) {
+			return nil, err
+		}, rawDecode)
+	}
+	responses := serveBatch(t, s, `[{"id":1,"jsonrpc":"2.0","method":"missing"},{"id":2,"jsonrpc":"2.0","method":"typed"},{"id":3,"jsonrpc":"2.0","method":"other"}]`)
+	for i, want := range []int{-32004, -32010, -32603} {
+		if resp := responses[i]; resp.Error == nil || resp.Error.Code != want {
+			t.Errorf("response %d: got %+v, want code %d", i, resp.Error, want)
+		}
+	}
+}
+
+func TestServerRegisterAlias(t *testing.T) {
+	var calls int
+	count := func(ctx context.Context, r *http.Request) (context.Context, error) {
+		calls++
+		return ctx, nil
+	}
+	s := jsonrpc.NewServer()
+	s.Register("eth_getBalance", echoEndpoint, rawDecode, jsonrpc.Before(count))
+	if err := s.RegisterAlias("eth_getBalance", "getBalance"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RegisterAlias("missing", "other"); err == nil {
+		t.Fatal("alias of an unregistered method was accepted")
+	}
+	for _, method := range []string{"eth_getBalance", "getBalance"} {
+		if body := serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"`+method+`","params":7}`).Body.String(); !strings.Contains(body, `"result":7`) {
+			t.Fatalf("%s: unexpected response %s", method, body)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("before func ran %d times, want 2", calls)
+	}
+}
+
+func TestServerCanceledBatch(t *testing.T) {
+	var calls int
+	ctx, cancel := context.WithCancel(context.Background())
+	s := jsonrpc.NewServer()
+	s.Register("work", func(ctx context.Context, request interface{}) (interface{}, error) {
+		calls++
+		cancel()
+		return "done", nil
+	}, rawDecode)
+	body := `[{"id":1,"jsonrpc":"2.0","method":"work"},{"id":2,"jsonrpc":"2.0","method":"work"},{"id":3,"jsonrpc":"2.0","method":"work"}]`
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(body)).WithContext(ctx))
+	var responses []testResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &responses); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("endpoint ran %d times after the context was canceled", calls)
+	}
+	if len(responses) != 3 || responses[0].Error != nil || responses[1].Error == nil || responses[2].Error == nil {
+		t.Fatalf("unexpected responses %+v", responses)
+	}
+	if code := responses[2].Error.Code; code != -32000 {
+		t.Fatalf("got error code %d for the canceled call, want -32000", code)
+	}
+}
+
+func TestServerRequestValidator(t *testing.T) {
+	var decoded int
+	validator := func(method string, params json.RawMessage) error {
+		if !bytes.Contains(params, []byte(`"to"`)) {
+			return errors.New(method + ": to is required")
+		}
+		return nil
+	}
+	s := jsonrpc.NewServer(jsonrpc.WithRequestValidator(validator))
+	s.Register("send", echoEndpoint, func(ctx context.Context, r *http.Request, params json.RawMessage) (any, error) {
+		decoded++
+		return params, nil
+	})
+	var resp testResponse
+	if err := json.Unmarshal(serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"send","params":{}}`).Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil || resp.Error.Code != -32602 || resp.Error.Data != "send: to is required" || decoded != 0 {
+		t.Fatalf("unexpected response %+v, decoded %d", resp.Error, decoded)
+	}
+	if body := serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"send","params":{"to":"x"}}`).Body.String(); !strings.Contains(body, `"result"`) {
+		t.Fatalf("valid params rejected: %s", body)
+	}
+}
+
+func TestServerJSON5(t *testing.T) {
+	s := jsonrpc.NewServer(jsonrpc.WithJSON5Support())
+	s.Register("echo", echoEndpoint, rawDecode)
+	body := `[
+		// first call
+		{"id":1,"jsonrpc":"2.0","method":"echo","params":["a // not a comment", "b /* nor this */",],},
+		/* second
+		   call */
+		{"id":2,"jsonrpc":"2.0","method":"echo","params":{"k":"v\"",}},
+	]`
+	responses := serveBatch(t, s, body)
+	if len(responses) != 2 || string(responses[0].Result) != `["a // not a comment","b /* nor this */"]` || string(responses[1].Result) != `{"k":"v\""}` {
+		t.Fatalf("unexpected responses %+v", responses)
+	}
+
+	var resp testResponse
+	if err := json.Unmarshal(serve(t, s, `{"id":1,"method":'echo'}`).Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil || resp.Error.Code != -32700 {
+		t.Fatalf("want parse error, got %+v", resp)
+	}
+	if err := json.Unmarshal(serve(t, jsonrpc.NewServer(), `{"id":1,"method":"echo",}`).Body.Bytes(), &resp); err != nil || resp.Error.Code != -32700 {
+		t.Fatalf("trailing comma accepted without WithJSON5Support: %+v", resp)
+	}
+}
+
+func TestNamedParamsDecode(t *testing.T) {
+	type params struct {
+		B int `jsonrpc:"1"`
+		A int `jsonrpc:"0"`
+	}
+	s := jsonrpc.NewServer()
+	s.Register("sub", func(ctx context.Context, request interface{}) (interface{}, error) {
+		p := request.(*params)
+		return p.A - p.B, nil
+	}, jsonrpc.NamedParamsDecode[params](nil))
+	for _, p := range []string{`{"A":5,"B":2}`, `[5,2]`} {
+		if body := serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"sub","params":`+p+`}`).Body.String(); body != `{"id":1,"jsonrpc":"2.0","result":3}`+"\n" {
+			t.Fatalf("params %s: unexpected response %s", p, body)
+		}
+	}
+	var resp testResponse
+	if err := json.Unmarshal(serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"sub","params":[1,2,3]}`).Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Fatalf("want invalid params, got %+v", resp)
+	}
+}
+
+func TestServerTimeoutStageTimings(t *testing.T) {
+	slow := func(ctx context.Context, request interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	s := jsonrpc.NewServer(jsonrpc.WithTimeout(20 * time.Millisecond))
+	s.Register("slow", slow, rawDecode)
+	s.Register("debug", slow, rawDecode, jsonrpc.WithStageTimings())
+	s.Register("slowDecode", echoEndpoint, func(ctx context.Context, r *http.Request, params json.RawMessage) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, jsonrpc.WithStageTimings())
+
+	resp := serveBatch(t, s, `[{"id":1,"jsonrpc":"2.0","method":"slow"},{"id":2,"jsonrpc":"2.0","method":"debug"},{"id":3,"jsonrpc":"2.0","method":"slowDecode"}]`)
+	if len(resp) != 3 {
+		t.Fatalf("got %d responses, want 3", len(resp))
+	}
+	if resp[0].Error == nil || resp[0].Error.Message != "timeout" || resp[0].Error.Data != nil {
+		t.Fatalf("unexpected response %+v", resp[0].Error)
+	}
+	stageTimings := func(resp testResponse) jsonrpc.StageTimings {
+		t.Helper()
+		if resp.Error == nil {
+			t.Fatalf("call %v did not time out", resp.ID)
+		}
+		data, _ := json.Marshal(resp.Error.Data)
+		var timings jsonrpc.StageTimings
+		if err := json.Unmarshal(data, &timings); err != nil {
+			t.Fatal(err)
+		}
+		return timings
+	}
+	if timings := stageTimings(resp[1]); timings.Stage != "endpoint" || timings.Endpoint < 20*time.Millisecond {
+		t.Fatalf("unexpected timings %+v", timings)
+	}
+	if timings := stageTimings(resp[2]); timings.Stage != "decode" || timings.Decode < 20*time.Millisecond || timings.Endpoint != 0 {
+		t.Fatalf("unexpected decode timings %+v", timings)
+	}
+}
+
+func TestServerIntrospection(t *testing.T) {
+	s := jsonrpc.NewServer(jsonrpc.WithIntrospection())
+	s.Register("echo", echoEndpoint, rawDecode).
+		Describe("Returns its params.", json.RawMessage(`{"type":"string"}`), json.RawMessage(`{"type":"string"}`))
+	s.Register("add", echoEndpoint, rawDecode)
+	body := serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"rpc.listMethods"}`).Body.String()
+	want := `{"id":1,"jsonrpc":"2.0","result":[{"name":"add"},{"name":"echo","description":"Returns its params.","params":{"type":"string"},"result":{"type":"string"}}]}` + "\n"
+	if body != want {
+		t.Fatalf("got %s", body)
+	}
+}
+
+func TestServerAuthMiddleware(t *testing.T) {
+	checkKey := func(ctx context.Context, r *http.Request) error {
+		if r.Header.Get("X-API-Key") != "k" {
+			return errors.New("bad api key")
+		}
+		return nil
+	}
+	for _, tc := range []struct {
+		opts []jsonrpc.Option
+		code int
+	}{
+		{[]jsonrpc.Option{jsonrpc.WithAuthMiddleware(checkKey)}, -32000},
+		{[]jsonrpc.Option{jsonrpc.WithAuthErrorCode(-32001), jsonrpc.WithAuthMiddleware(checkKey)}, -32001},
+	} {
+		s := jsonrpc.NewServer(tc.opts...)
+		s.Register("echo", echoEndpoint, rawDecode)
+		var resp testResponse
+		if err := json.Unmarshal(serve(t, s, `{"id":1,"jsonrpc":"2.0","method":"echo","params":1}`).Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Error == nil || resp.Error.Code != tc.code || resp.Error.Data != "bad api key" {
+			t.Fatalf("want code %d, got %+v", tc.code, resp.Error)
+		}
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"id":1,"jsonrpc":"2.0","method":"echo","params":1}`))
+		r.Header.Set("X-API-Key", "k")
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if !strings.Contains(w.Body.String(), `"result":1`) {
+			t.Fatalf("valid key rejected: %s", w.Body.String())
+		}
+	}
+}
+
+type seenKey struct{}
+
+func TestServerBatchContextAccumulates(t *testing.T) {
+	count := func(ctx context.Context, r *http.Request) (context.Context, error) {
+		n, _ := ctx.Value(seenKey{}).(int)
+		return context.WithValue(ctx, seenKey{}, n+1), nil
+	}
+	s := jsonrpc.NewServer(jsonrpc.Before(count))
+	s.Register("seen", func(ctx context.Context, request interface{}) (interface{}, error) {
+		return ctx.Value(seenKey{}), nil
+	}, rawDecode)
+	responses := serveBatch(t, s, `[{"id":1,"jsonrpc":"2.0","method":"seen"},{"id":2,"jsonrpc":"2.0","method":"seen"},{"id":3,"jsonrpc":"2.0","method":"seen"}]`)
+	for i, resp := range responses {
+		if string(resp.Result) != strconv.Itoa(i+1) {
+			t.Fatalf("call %d saw %s", i, resp.Result)
+		}
+	}
+}
+
+func TestRequestSignature(t *testing.T) {
+	s := jsonrpc.NewServer(jsonrpc.Before(jsonrpc.VerifySignature([]byte("secret"))))
+	s.Register("echo", echoEndpoint, rawDecode)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	for key, wantErr := range map[string]bool{"secret": false, "wrong": true} {
+		c := jsonrpc.NewClient(ts.URL, jsonrpc.BeforeRequest(jsonrpc.SignRequest([]byte(key))))
+		result, err := c.Execute(&echoRequest{method: "echo", params: "a"}, &echoRequest{method: "echo", params: "b"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			if gotErr := result.Error(i) != nil; gotErr != wantErr {
+				t.Fatalf("key %s, call %d: error %v", key, i, result.Error(i))
+			}
+		}
+	}
+
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithHTTPMethod(http.MethodGet), jsonrpc.BeforeRequest(jsonrpc.SignRequest([]byte("secret"))))
+	if _, err := c.Execute(&echoRequest{method: "echo", params: "a"}); err == nil || !strings.Contains(err.Error(), "cannot sign request") {
+		t.Fatalf("got %v, want a signing error for a GET call", err)
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	s := jsonrpc.NewServer()
+	started, release := make(chan struct{}), make(chan struct{})
+	s.Register("slow", func(ctx context.Context, request interface{}) (interface{}, error) {
+		close(started)
+		<-release
+		return "done", nil
+	}, rawDecode)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		result, err := jsonrpc.NewClient(ts.URL).Execute(&echoRequest{method: "slow"})
+		if err == nil && result.At(0) != "done" {
+			err = fmt.Errorf("got %v", result.At(0))
+		}
+		errc <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want DeadlineExceeded", err)
+	}
+	resp, err := http.Post(ts.URL, "application/json", strings.NewReader(`{"id":1,"jsonrpc":"2.0","method":"slow"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got status %d, want 503", resp.StatusCode)
+	}
+
+	close(release)
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
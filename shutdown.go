@@ -0,0 +1,57 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+)
+
+// Shutdown makes ServeHTTP and SSEHandler answer new requests with 503
+// Service Unavailable and waits until the requests in flight have been
+// answered or ctx is done. In the latter case the open SSE streams are
+// canceled and waited for, and ctx.Err() is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shutdownMu.Lock()
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+	s.shutdownMu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		s.cancelStreams()
+		s.streams.Wait()
+		return ctx.Err()
+	}
+}
+
+// begin counts a request as in flight unless the server is shutting down.
+// stream marks an SSE stream, which Shutdown cancels once its ctx is done.
+func (s *Server) begin(stream bool) bool {
+	s.shutdownMu.Lock()
+	defer s.shutdownMu.Unlock()
+	select {
+	case <-s.done:
+		return false
+	default:
+		s.inflight.Add(1)
+		if stream {
+			s.streams.Add(1)
+		}
+		return true
+	}
+}
+
+func (s *Server) writeUnavailable(w http.ResponseWriter) {
+	s.setHeaders(w)
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_ = s.opts.codec.NewEncoder(w).Encode(s.makeErrorResponse(RPCID{}, jsonRPCServerError, "server shutting down"))
+}
@@ -0,0 +1,70 @@
+package jsonrpc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var errNoBody = errors.New("jsonrpc: request body cannot be read again")
+
+// SignatureHeader carries the hex encoded HMAC-SHA256 of the request body.
+const SignatureHeader = "X-Signature"
+
+// SignRequest returns a ClientBeforeFunc that signs the encoded body with
+// key into SignatureHeader. Calls whose body cannot be read again, such as
+// GET requests, fail instead of being sent unsigned or signed empty.
+func SignRequest(key []byte) ClientBeforeFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		body, err := requestBody(r)
+		if err != nil {
+			return contextWithRequestError(ctx, fmt.Errorf("jsonrpc: cannot sign request: %w", err))
+		}
+		r.Header.Set(SignatureHeader, hex.EncodeToString(bodyMAC(key, body)))
+		return ctx
+	}
+}
+
+// VerifySignature returns a BeforeFunc that fails calls whose
+// SignatureHeader is not the HMAC-SHA256 of the request body under key
+// with ErrorCodeUnauthorized.
+func VerifySignature(key []byte) BeforeFunc {
+	return func(ctx context.Context, r *http.Request) (context.Context, error) {
+		sig, err := hex.DecodeString(r.Header.Get(SignatureHeader))
+		if err != nil || len(sig) == 0 {
+			return ctx, NewError(ErrorCodeUnauthorized, "invalid signature", nil)
+		}
+		body, err := requestBody(r)
+		if err != nil {
+			return ctx, err
+		}
+		if !hmac.Equal(sig, bodyMAC(key, body)) {
+			return ctx, NewError(ErrorCodeUnauthorized, "invalid signature", nil)
+		}
+		return ctx, nil
+	}
+}
+
+func bodyMAC(key, body []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// requestBody reads r's body without consuming it for later readers.
+func requestBody(r *http.Request) ([]byte, error) {
+	if r.GetBody == nil {
+		return nil, errNoBody
+	}
+	body, err := r.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
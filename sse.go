@@ -0,0 +1,165 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// SubscriptionEndpoint starts a subscription and returns the channel of
+// values to push to the client. It must stop sending and close the channel
+// once ctx is done.
+type SubscriptionEndpoint func(ctx context.Context, request any) (<-chan any, error)
+
+// UnsubscribeMethod is registered on the server together with the first
+// subscription. It takes the subscription id as its only positional param
+// and ends that stream.
+const UnsubscribeMethod = "rpc.unsubscribe"
+
+type subscriptionNotification struct {
+	Version string             `json:"jsonrpc"`
+	Method  string             `json:"method"`
+	Params  subscriptionParams `json:"params"`
+}
+
+type subscriptionParams struct {
+	Subscription string `json:"subscription"`
+	Result       any    `json:"result"`
+}
+
+// RegisterSubscription registers a method that is only served by
+// SSEHandler. Before funcs, middleware and request validation apply as for
+// normal methods; the middleware sees the channel as the response.
+func (s *Server) RegisterSubscription(method string, endpoint SubscriptionEndpoint, reqDecode ReqDecode, opts ...Option) *ServerMethod {
+	sm := s.newMethod(method, func(ctx context.Context, request any) (any, error) {
+		return endpoint(ctx, request)
+	}, reqDecode, opts)
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	if s.subscriptions == nil {
+		s.subscriptions = map[string]*ServerMethod{}
+		s.subs = map[string]context.CancelFunc{}
+		s.Register(UnsubscribeMethod, s.unsubscribe, func(ctx context.Context, r *http.Request, params json.RawMessage) (any, error) {
+			var id []string
+			if err := s.opts.codec.Unmarshal(params, &id); err != nil || len(id) != 1 {
+				return nil, NewError(jsonRPCInvalidParamsError, "want [subscription id]", nil)
+			}
+			return id[0], nil
+		})
+	}
+	s.subscriptions[method] = sm
+	return sm
+}
+
+func (s *Server) unsubscribe(ctx context.Context, request any) (any, error) {
+	s.subsMu.Lock()
+	cancel, ok := s.subs[request.(string)]
+	s.subsMu.Unlock()
+	if ok {
+		cancel()
+	}
+	return ok, nil
+}
+
+// SSEHandler serves subscription methods as Server-Sent Events. The client
+// POSTs a single request; the first event is the response carrying the
+// subscription id, and every value from the channel follows as a
+// notification named after the method with params
+// {"subscription": id, "result": value}. The stream ends when the channel
+// is closed, the client disconnects or the id is passed to
+// UnsubscribeMethod; each of these cancels the endpoint's context. Errors
+// before the stream starts are sent as a plain JSON-RPC response.
+func (s *Server) SSEHandler() http.Handler {
+	return http.HandlerFunc(s.serveSSE)
+}
+
+func (s *Server) serveSSE(w http.ResponseWriter, r *http.Request) {
+	if !s.begin(true) {
+		s.writeUnavailable(w)
+		return
+	}
+	defer s.streams.Done()
+	defer s.inflight.Done()
+	var req Request
+	if err := s.opts.codec.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeSSEError(w, s.makeErrorResponse(RPCID{}, jsonRPCParseError, err.Error()))
+		return
+	}
+	s.subsMu.Lock()
+	method, ok := s.subscriptions[req.Method]
+	s.subsMu.Unlock()
+	if !ok {
+		s.writeSSEError(w, s.makeErrorResponse(req.ID, jsonRPCMethodNotFoundError, "subscription "+req.Method+" not found"))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	stop := context.AfterFunc(s.streamsCtx, cancel)
+	defer stop()
+	_, result, err := s.handleMethod(method, ctx, w, r, req)
+	if err != nil {
+		s.writeSSEError(w, s.makeErrorResponseFromError(req.ID, err))
+		return
+	}
+	ch, ok := result.(<-chan any)
+	if !ok {
+		s.writeSSEError(w, s.makeErrorResponseFromError(req.ID, errors.New("jsonrpc: subscription did not return a channel")))
+		return
+	}
+	id := newUUID()
+	s.subsMu.Lock()
+	s.subs[id] = cancel
+	s.subsMu.Unlock()
+	defer func() {
+		s.subsMu.Lock()
+		delete(s.subs, id)
+		s.subsMu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	data, err := s.opts.codec.Marshal(id)
+	if err != nil {
+		return
+	}
+	if err := s.writeEvent(w, jsonRPCResponse{ID: req.ID, Version: Version, Result: data}); err != nil {
+		return
+	}
+	flusher.Flush()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case v, ok := <-ch:
+			if !ok {
+				return
+			}
+			n := subscriptionNotification{Version: Version, Method: req.Method, Params: subscriptionParams{Subscription: id, Result: v}}
+			if err := s.writeEvent(w, n); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (s *Server) writeEvent(w io.Writer, v any) error {
+	data, err := s.opts.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "data: "+string(data)+"\n\n")
+	return err
+}
+
+func (s *Server) writeSSEError(w http.ResponseWriter, resp jsonRPCResponse) {
+	s.setHeaders(w)
+	_ = s.opts.codec.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,169 @@
+package jsonrpc_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/555f/jsonrpc"
+)
+
+type sseEvent struct {
+	Result json.RawMessage `json:"result"`
+	Method string          `json:"method"`
+	Params struct {
+		Subscription string `json:"subscription"`
+		Result       int    `json:"result"`
+	} `json:"params"`
+}
+
+func readEvents(t *testing.T, resp *http.Response, n int) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	sc := bufio.NewScanner(resp.Body)
+	for len(events) < n && sc.Scan() {
+		data, ok := strings.CutPrefix(sc.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var e sseEvent
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func subscribe(t *testing.T, url, method string) *http.Response {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", strings.NewReader(`{"id":1,"jsonrpc":"2.0","method":"`+method+`","params":null}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestServerSSESubscription(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.RegisterSubscription("count", func(ctx context.Context, request any) (<-chan any, error) {
+		ch := make(chan any)
+		go func() {
+			defer close(ch)
+			for i := 1; i <= 3; i++ {
+				select {
+				case ch <- i:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return ch, nil
+	}, rawDecode)
+	stopped := make(chan struct{})
+	s.RegisterSubscription("forever", func(ctx context.Context, request any) (<-chan any, error) {
+		ch := make(chan any)
+		go func() {
+			defer close(stopped)
+			for i := 1; ; i++ {
+				select {
+				case ch <- i:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return ch, nil
+	}, rawDecode)
+	ts := httptest.NewServer(s.SSEHandler())
+	defer ts.Close()
+
+	resp := subscribe(t, ts.URL, "count")
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	events := readEvents(t, resp, 10)
+	if len(events) != 4 || events[0].Result == nil || events[3].Method != "count" || events[3].Params.Result != 3 {
+		t.Fatalf("unexpected events %+v", events)
+	}
+
+	resp = subscribe(t, ts.URL, "forever")
+	events = readEvents(t, resp, 2)
+	var id string
+	if err := json.Unmarshal(events[0].Result, &id); err != nil || events[1].Params.Subscription != id {
+		t.Fatalf("subscription id %q, events %+v", id, events)
+	}
+	if body := serve(t, s, `{"id":2,"jsonrpc":"2.0","method":"rpc.unsubscribe","params":["`+id+`"]}`).Body.String(); body != `{"id":2,"jsonrpc":"2.0","result":true}`+"\n" {
+		t.Fatalf("unexpected unsubscribe response %s", body)
+	}
+	<-stopped
+
+	if body := serve(t, s, `{"id":3,"jsonrpc":"2.0","method":"count","params":null}`).Body.String(); !strings.Contains(body, "-32601") {
+		t.Fatalf("subscription served by ServeHTTP: %s", body)
+	}
+}
+
+func TestServerSSEAfter(t *testing.T) {
+	s := jsonrpc.NewServer(jsonrpc.After(func(ctx context.Context, rw http.ResponseWriter) context.Context {
+		if _, ok := jsonrpc.ResponseFromContext(ctx); ok {
+			t.Error("ResponseFromContext returned a response for a subscription")
+		}
+		rw.Header().Set("X-After", "ran")
+		return ctx
+	}))
+	s.RegisterSubscription("once", func(ctx context.Context, request any) (<-chan any, error) {
+		ch := make(chan any, 1)
+		ch <- 1
+		close(ch)
+		return ch, nil
+	}, rawDecode)
+	ts := httptest.NewServer(s.SSEHandler())
+	defer ts.Close()
+
+	resp := subscribe(t, ts.URL, "once")
+	if got := resp.Header.Get("X-After"); got != "ran" {
+		t.Fatalf("X-After = %q", got)
+	}
+	events := readEvents(t, resp, 10)
+	if len(events) != 2 || events[0].Result == nil || events[1].Params.Result != 1 {
+		t.Fatalf("unexpected events %+v", events)
+	}
+}
+
+func TestServerShutdownSSE(t *testing.T) {
+	s := jsonrpc.NewServer()
+	canceled := make(chan struct{})
+	s.RegisterSubscription("forever", func(ctx context.Context, request any) (<-chan any, error) {
+		go func() {
+			<-ctx.Done()
+			close(canceled)
+		}()
+		return make(chan any), nil
+	}, rawDecode)
+	ts := httptest.NewServer(s.SSEHandler())
+	defer ts.Close()
+
+	if events := readEvents(t, subscribe(t, ts.URL, "forever"), 1); len(events) != 1 {
+		t.Fatalf("got %d events, want the subscription id", len(events))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want DeadlineExceeded", err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("open stream was not canceled")
+	}
+	if resp := subscribe(t, ts.URL, "forever"); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got status %d, want 503", resp.StatusCode)
+	}
+}
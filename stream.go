@@ -0,0 +1,92 @@
+package jsonrpc
+
+import (
+	"context"
+	"io"
+	"os/exec"
+)
+
+type streamConn struct {
+	r *frameReader
+	w io.Writer
+	c []io.Closer
+}
+
+func (c *streamConn) ReadMessage() ([]byte, error) {
+	return c.r.ReadMessage()
+}
+
+func (c *streamConn) WriteMessage(data []byte) error {
+	return writeFrame(c.w, data)
+}
+
+func (c *streamConn) Close() (err error) {
+	for _, closer := range c.c {
+		if cerr := closer.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// NewStreamClient returns a Client that exchanges Content-Length framed
+// messages over r and w, as used by LSP-style tools on stdin/stdout.
+// Concurrent calls share the stream and are correlated by id; once the
+// stream fails every later call returns an error. A response frame larger
+// than 32 MiB fails the stream.
+func NewStreamClient(r io.Reader, w io.Writer, opts ...ClientOption) *Client {
+	c := NewClient("", opts...)
+	conn := &streamConn{r: newFrameReader(r, 0), w: w}
+	for _, v := range []any{w, r} {
+		if closer, ok := v.(io.Closer); ok {
+			conn.c = append(conn.c, closer)
+		}
+	}
+	used := false
+	c.opts.transport = c.newMuxTransport(func(ctx context.Context) (messageConn, error) {
+		if used {
+			return nil, errConnClosed
+		}
+		used = true
+		return conn, nil
+	}, false)
+	c.ownsTransport = true
+	return c
+}
+
+// Serve reads Content-Length framed messages from r, as used by the
+// Language Server Protocol, and writes the framed responses to w until r
+// fails, returning nil at EOF. Messages are handled like in ServeConn. A
+// frame with a missing or invalid Content-Length, or one longer than the
+// WithMaxRequestBytes limit (32 MiB by default), is answered with a parse
+// error and skipped.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	return s.serveMessages(&streamConn{r: newFrameReader(r, s.opts.maxRequestBytes), w: w}, "")
+}
+
+type commandOutput struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (o *commandOutput) Close() error {
+	return o.cmd.Wait()
+}
+
+// NewCommandClient starts cmd and returns a Client that talks to it over
+// its stdin and stdout like NewStreamClient. Close closes stdin and waits
+// for the process to exit.
+func NewCommandClient(cmd *exec.Cmd, opts ...ClientOption) (*Client, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return NewStreamClient(&commandOutput{ReadCloser: stdout, cmd: cmd}, stdin, opts...), nil
+}
@@ -0,0 +1,211 @@
+package jsonrpc_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/555f/jsonrpc"
+)
+
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	var n int
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if v, ok := strings.CutPrefix(line, "Content-Length: "); ok {
+			n, _ = strconv.Atoi(v)
+		}
+	}
+	data := make([]byte, n)
+	_, err := io.ReadFull(r, data)
+	return data, err
+}
+
+// serveFrames answers framed requests concurrently, so replies may be
+// written in a different order than the requests arrived.
+func serveFrames(s *jsonrpc.Server, r io.Reader, w io.Writer) {
+	br := bufio.NewReader(r)
+	var mu sync.Mutex
+	for {
+		data, err := readFrame(br)
+		if err != nil {
+			return
+		}
+		go func() {
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(string(data))))
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", rec.Body.Len(), rec.Body.Bytes())
+		}()
+	}
+}
+
+func TestStreamClient(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	s.Register("slow", func(ctx context.Context, request interface{}) (interface{}, error) {
+		time.Sleep(100 * time.Millisecond)
+		return "late", nil
+	}, rawDecode)
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	go serveFrames(s, serverR, serverW)
+	c := jsonrpc.NewStreamClient(clientR, clientW)
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.ExecuteWithContext(ctx, &echoRequest{method: "slow"}); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := c.Execute(&echoRequest{method: "echo", params: strconv.Itoa(i)})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got := result.At(0); got != strconv.Itoa(i) {
+				t.Errorf("got %v, want %d", got, i)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestStreamClientOversizedFrame(t *testing.T) {
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	go func() {
+		if _, err := readFrame(bufio.NewReader(serverR)); err == nil {
+			fmt.Fprint(serverW, "Content-Length: 99999999999999\r\n\r\n{}")
+		}
+	}()
+	c := jsonrpc.NewStreamClient(clientR, clientW)
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := c.ExecuteWithContext(ctx, &echoRequest{method: "echo"}); !errors.Is(err, jsonrpc.ErrConnectionLost) {
+		t.Fatalf("got %v, want ErrConnectionLost", err)
+	}
+}
+
+func TestServerServe(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	req := `{"id":1,"jsonrpc":"2.0","method":"echo","params":"lsp"}`
+	in := strings.NewReader("Content-Length: abc\r\n\r\n" +
+		fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(req), req))
+	var out strings.Builder
+	if err := s.Serve(in, &out); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(strings.NewReader(out.String()))
+	var responses []string
+	for {
+		data, err := readFrame(r)
+		if err != nil {
+			break
+		}
+		responses = append(responses, string(data))
+	}
+	if len(responses) != 2 || !strings.Contains(responses[0], "-32700") || responses[1] != `{"id":1,"jsonrpc":"2.0","result":"lsp"}` {
+		t.Fatalf("unexpected responses %q", responses)
+	}
+}
+
+func TestServerServeOversizedFrame(t *testing.T) {
+	s := jsonrpc.NewServer(jsonrpc.WithMaxRequestBytes(1024))
+	s.Register("echo", echoEndpoint, rawDecode)
+	req := `{"id":1,"jsonrpc":"2.0","method":"echo","params":"lsp"}`
+	big := strings.Repeat(" ", 2048)
+	in := strings.NewReader(fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(big), big) +
+		fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(req), req) +
+		"Content-Length: 99999999999999\r\n\r\n{}")
+	var out strings.Builder
+	if err := s.Serve(in, &out); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(strings.NewReader(out.String()))
+	var responses []string
+	for {
+		data, err := readFrame(r)
+		if err != nil {
+			break
+		}
+		responses = append(responses, string(data))
+	}
+	var parseErrors, results int
+	for _, resp := range responses {
+		switch {
+		case strings.Contains(resp, "-32700"):
+			parseErrors++
+		case resp == `{"id":1,"jsonrpc":"2.0","result":"lsp"}`:
+			results++
+		}
+	}
+	if len(responses) != 3 || parseErrors != 2 || results != 1 {
+		t.Fatalf("unexpected responses %q", responses)
+	}
+}
+
+func TestHelperStdioServer(t *testing.T) {
+	if os.Getenv("JSONRPC_STDIO_SERVER") != "1" {
+		t.Skip("helper process")
+	}
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	_ = s.Serve(os.Stdin, os.Stdout)
+	os.Exit(0)
+}
+
+func TestCommandClient(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperStdioServer$")
+	cmd.Env = append(os.Environ(), "JSONRPC_STDIO_SERVER=1")
+	c, err := jsonrpc.NewCommandClient(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := c.Execute(&echoRequest{method: "echo", params: strconv.Itoa(i)})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got := result.At(0); got != strconv.Itoa(i) {
+				t.Errorf("got %v, want %d", got, i)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
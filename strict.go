@@ -0,0 +1,79 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidResponse = errors.New("jsonrpc: invalid response")
+
+// WithStrictResponses makes the client check every response entry: the
+// jsonrpc member must be "2.0", the id must be present and not null, and
+// result and error must not both be present. An entry that fails gets a
+// *Error wrapping ErrInvalidResponse in its slot instead of a result. A
+// response id that appears twice or matches no request, including a
+// missing id, fails the whole call.
+func WithStrictResponses() ClientOption {
+	return func(o *clientOptions) {
+		o.strict = true
+	}
+}
+
+// OnProtocolViolation registers f to be told about response problems the
+// client works around when it is not in strict mode: a repeated response
+// id, where only the first entry is used, and a response matching no
+// request, which is dropped.
+func OnProtocolViolation(f func(ctx context.Context, err error)) ClientOption {
+	return func(o *clientOptions) {
+		o.onViolation = f
+	}
+}
+
+type responseShape struct {
+	ID      json.RawMessage `json:"id"`
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+	Error   json.RawMessage `json:"error"`
+}
+
+func (r responseShape) validate() error {
+	var reason string
+	switch {
+	case r.Version != Version:
+		reason = `jsonrpc member is not "2.0"`
+	case len(r.ID) == 0 || string(r.ID) == "null":
+		reason = "id is missing"
+	case r.Result != nil && r.Error != nil:
+		reason = "both result and error are present"
+	default:
+		return nil
+	}
+	return &Error{code: jsonRPCInternalError, message: ErrInvalidResponse.Error() + ": " + reason, err: ErrInvalidResponse}
+}
+
+// checkResponses returns the protocol error for each entry of data, or nil
+// for valid entries.
+func (c *Client) checkResponses(data []byte, n int) ([]error, error) {
+	shapes := make([]responseShape, 0, n)
+	if c.singleResponse(data) {
+		shapes = make([]responseShape, 1)
+		if err := c.opts.codec.Unmarshal(data, &shapes[0]); err != nil {
+			return nil, err
+		}
+	} else if err := c.opts.codec.Unmarshal(data, &shapes); err != nil {
+		return nil, err
+	}
+	errs := make([]error, len(shapes))
+	for i, shape := range shapes {
+		errs[i] = shape.validate()
+	}
+	return errs, nil
+}
+
+func responseIDString(id json.RawMessage) string {
+	if len(id) == 0 {
+		return "null"
+	}
+	return string(id)
+}
@@ -0,0 +1,48 @@
+package jsonrpc
+
+import (
+	"context"
+)
+
+// RequesterWithTarget sends a request to another URL than the client's.
+// Execute groups a batch by target, makes one call per distinct target
+// and merges the results back in the original order. Requests with an
+// empty Target go to the client's own URL.
+type RequesterWithTarget interface {
+	Requester
+	Target() string
+}
+
+func requestTarget(r Requester) string {
+	if v, ok := r.(RequesterWithTarget); ok {
+		return v.Target()
+	}
+	return ""
+}
+
+// byTarget groups request indexes by target in order of first appearance.
+// It returns nil when every request goes to the same target.
+func byTarget(requests []Requester) [][]int {
+	var groups [][]int
+	index := map[string]int{}
+	for i, r := range requests {
+		t := requestTarget(r)
+		g, ok := index[t]
+		if !ok {
+			g = len(groups)
+			index[t] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	if len(groups) <= 1 {
+		return nil
+	}
+	return groups
+}
+
+// executeTargets runs each target group through the usual pipeline, so a
+// failing target only affects its own entries.
+func (c *Client) executeTargets(ctx context.Context, requests []Requester, groups [][]int) (*BatchResult, error) {
+	return c.runGroups(ctx, requests, groups, c.executeBatch)
+}
@@ -0,0 +1,196 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// lineConn frames messages as newline-delimited JSON: every message is a
+// single request, batch or response, compacted onto one line.
+type lineConn struct {
+	conn  net.Conn
+	r     *bufio.Reader
+	limit int64
+	skip  bool // the rest of a rejected line is still to discard
+}
+
+// newLineConn reads lines of at most limit bytes, or
+// defaultMaxMessageBytes if limit is not positive.
+func newLineConn(conn net.Conn, limit int64) *lineConn {
+	if limit <= 0 {
+		limit = defaultMaxMessageBytes
+	}
+	return &lineConn{conn: conn, r: bufio.NewReader(conn), limit: limit}
+}
+
+func (c *lineConn) ReadMessage() ([]byte, error) {
+	for {
+		line, err := c.readLine()
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *lineConn) readLine() ([]byte, error) {
+	for c.skip {
+		_, err := c.r.ReadSlice('\n')
+		if err == nil {
+			c.skip = false
+		} else if err != bufio.ErrBufferFull {
+			return nil, err
+		}
+	}
+	var line []byte
+	for {
+		chunk, err := c.r.ReadSlice('\n')
+		if int64(len(line)+len(chunk)) > c.limit {
+			c.skip = err == bufio.ErrBufferFull
+			return nil, fmt.Errorf("%w: line exceeds %d bytes", errBadFrame, c.limit)
+		}
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+func (c *lineConn) WriteMessage(data []byte) error {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err := c.conn.Write(buf.Bytes())
+	return err
+}
+
+func (c *lineConn) Close() error {
+	return c.conn.Close()
+}
+
+// NewTCPClient returns a Client that speaks newline-delimited JSON-RPC
+// over a TCP connection to addr, as served by Server.ServeConn. The
+// connection is dialed on first use and again after it fails.
+func NewTCPClient(addr string, opts ...ClientOption) *Client {
+	c := NewClient("", opts...)
+	c.opts.transport = c.newMuxTransport(func(ctx context.Context) (messageConn, error) {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return newLineConn(conn, 0), nil
+	}, true)
+	c.ownsTransport = true
+	return c
+}
+
+// NewConnClient is like NewTCPClient but uses conn, which is not redialed
+// once it fails.
+func NewConnClient(conn net.Conn, opts ...ClientOption) *Client {
+	c := NewClient("", opts...)
+	used := false
+	c.opts.transport = c.newMuxTransport(func(ctx context.Context) (messageConn, error) {
+		if used {
+			return nil, errConnClosed
+		}
+		used = true
+		return newLineConn(conn, 0), nil
+	}, false)
+	c.ownsTransport = true
+	return c
+}
+
+type connResponseWriter struct {
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *connResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *connResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *connResponseWriter) WriteHeader(int) {}
+
+// ServeConn serves newline-delimited JSON-RPC on conn until reading fails,
+// then closes it. At EOF it returns nil once pending calls are answered;
+// other read errors cancel them. Each line holds one request or batch and
+// is answered with one line in any order, so clients match responses by
+// id. Messages are handled like POST bodies by ServeHTTP, up to 64 at a
+// time, with a request that carries conn's remote address; notifications
+// get no reply and HTTP headers set by hooks are dropped. A line longer
+// than the WithMaxRequestBytes limit (32 MiB by default) is answered with
+// a parse error and skipped.
+func (s *Server) ServeConn(conn net.Conn) error {
+	defer conn.Close()
+	return s.serveMessages(newLineConn(conn, s.opts.maxRequestBytes), conn.RemoteAddr().String())
+}
+
+// maxConnCalls bounds the messages handled at once on one connection;
+// reading pauses while that many are in flight.
+const maxConnCalls = 64
+
+func (s *Server) serveMessages(conn messageConn, remoteAddr string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConnCalls)
+	var writeMu sync.Mutex
+	defer cancel()
+	write := func(data []byte) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		_ = conn.WriteMessage(data)
+	}
+	for {
+		data, err := conn.ReadMessage()
+		if errors.Is(err, errBadFrame) {
+			if data, err := s.opts.codec.Marshal(s.makeErrorResponse(RPCID{}, jsonRPCParseError, err.Error())); err == nil {
+				write(data)
+			}
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			wg.Wait()
+			return nil
+		}
+		if err != nil {
+			cancel()
+			wg.Wait()
+			return err
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			r, err := http.NewRequestWithContext(ctx, "POST", "/", bytes.NewReader(data))
+			if err != nil {
+				return
+			}
+			r.RemoteAddr = remoteAddr
+			w := &connResponseWriter{header: http.Header{}}
+			s.ServeHTTP(w, r)
+			if body := bytes.TrimSpace(w.body.Bytes()); len(body) > 0 {
+				write(body)
+			}
+		}()
+	}
+}
@@ -0,0 +1,90 @@
+package jsonrpc_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/555f/jsonrpc"
+)
+
+func TestServeConn(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	serverConn, clientConn := net.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- s.ServeConn(serverConn) }()
+
+	c := jsonrpc.NewConnClient(clientConn)
+	result, err := c.Execute(echoRequests(3)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"0", "1", "2"} {
+		if got := result.At(i); got != want {
+			t.Fatalf("result %d = %v, want %s", i, got, want)
+		}
+	}
+	if result, err := c.Execute(&echoRequest{method: "echo", params: "single"}); err != nil || result.At(0) != "single" {
+		t.Fatalf("got %v, %v", result, err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("ServeConn returned %v", err)
+	}
+}
+
+func TestServeConnLimits(t *testing.T) {
+	var running, peak int64
+	release := make(chan struct{})
+	s := jsonrpc.NewServer(jsonrpc.WithMaxRequestBytes(1024))
+	s.Register("echo", echoEndpoint, rawDecode)
+	s.Register("block", func(ctx context.Context, request interface{}) (interface{}, error) {
+		n := atomic.AddInt64(&running, 1)
+		defer atomic.AddInt64(&running, -1)
+		for {
+			p := atomic.LoadInt64(&peak)
+			if n <= p || atomic.CompareAndSwapInt64(&peak, p, n) {
+				break
+			}
+		}
+		<-release
+		return nil, nil
+	}, rawDecode)
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	go func() { _ = s.ServeConn(serverConn) }()
+
+	const calls = 100
+	go func() {
+		fmt.Fprintf(clientConn, "%s\n", strings.Repeat(" ", 64<<10)+"{}")
+		fmt.Fprintln(clientConn, `{"id":1,"jsonrpc":"2.0","method":"echo","params":"small"}`)
+		for i := 0; i < calls; i++ {
+			fmt.Fprintf(clientConn, `{"id":%d,"jsonrpc":"2.0","method":"block"}`+"\n", i+2)
+		}
+	}()
+	r := bufio.NewReader(clientConn)
+	for _, want := range []string{"-32700", `"result":"small"`} {
+		line, err := r.ReadString('\n')
+		if err != nil || !strings.Contains(line, want) {
+			t.Fatalf("got %q, %v, want a line containing %s", line, err, want)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for i := 0; i < calls; i++ {
+		if _, err := r.ReadString('\n'); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if peak := atomic.LoadInt64(&peak); peak != 64 {
+		t.Fatalf("%d calls ran at once, want 64", peak)
+	}
+}
@@ -0,0 +1,89 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WithTimeout bounds each call of a method, or of every method when given
+// to NewServer, to d, counted from when the Before funcs are done.
+// Endpoints see the deadline through their context; a call that fails
+// after it passed is answered with a -32000 "timeout" error.
+func WithTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.timeout = d
+	}
+}
+
+// WithStageTimings adds a StageTimings value as the data of timeout errors
+// so it is visible which part of handling a call used up the time.
+func WithStageTimings() Option {
+	return func(o *Options) {
+		o.stageTimings = true
+	}
+}
+
+// StageTimings is the data of a timeout error under WithStageTimings.
+// Stage names the part that was running when the deadline passed: decode,
+// middleware or endpoint. Before is reported but not bounded by the
+// timeout.
+type StageTimings struct {
+	Stage      string        `json:"stage"`
+	Before     time.Duration `json:"before"`
+	Decode     time.Duration `json:"decode"`
+	Middleware time.Duration `json:"middleware"`
+	Endpoint   time.Duration `json:"endpoint"`
+}
+
+type stageTimer struct {
+	start, before, decode, chainStart, chainEnd, endpointStart, endpointEnd time.Time
+}
+
+func (t *stageTimer) wrap(endpoint Endpoint) Endpoint {
+	return func(ctx context.Context, request any) (any, error) {
+		t.endpointStart = time.Now()
+		defer func() { t.endpointEnd = time.Now() }()
+		return endpoint(ctx, request)
+	}
+}
+
+func (t *stageTimer) timings(deadline time.Time) StageTimings {
+	decode := t.decode
+	if decode.IsZero() {
+		// Decoding or validation failed, so it ran until now.
+		decode = time.Now()
+	}
+	st := StageTimings{
+		Before: t.before.Sub(t.start),
+		Decode: decode.Sub(t.before),
+	}
+	if !t.chainStart.IsZero() {
+		st.Middleware = t.chainEnd.Sub(t.chainStart)
+	}
+	if !t.endpointStart.IsZero() {
+		st.Endpoint = t.endpointEnd.Sub(t.endpointStart)
+		st.Middleware -= st.Endpoint
+	}
+	switch {
+	case t.decode.IsZero() || t.decode.After(deadline):
+		st.Stage = "decode"
+	case !t.endpointStart.IsZero() && t.endpointStart.Before(deadline) && !t.endpointEnd.Before(deadline):
+		st.Stage = "endpoint"
+	default:
+		st.Stage = "middleware"
+	}
+	return st
+}
+
+func (s *Server) timeoutError(ctx context.Context, timer *stageTimer, err error) error {
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	rpcErr := &Error{code: jsonRPCServerError, message: "timeout", err: err}
+	if timer != nil {
+		deadline, _ := ctx.Deadline()
+		rpcErr.data = timer.timings(deadline)
+	}
+	return rpcErr
+}
@@ -0,0 +1,243 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type TransportMeta struct {
+	Request  *http.Request
+	Response *http.Response
+}
+
+type Transport interface {
+	RoundTrip(ctx context.Context, payload []byte) (body io.ReadCloser, meta TransportMeta, err error)
+}
+
+func WithTransport(transport Transport) ClientOption {
+	return func(o *clientOptions) {
+		o.transport = transport
+	}
+}
+
+type clientBeforeKey struct{}
+
+type requestErrorKey struct{}
+
+// contextWithRequestError lets a ClientBeforeFunc fail the call it runs
+// for: the request is not sent and Execute returns err.
+func contextWithRequestError(ctx context.Context, err error) context.Context {
+	return context.WithValue(ctx, requestErrorKey{}, err)
+}
+
+func requestErrorFromContext(ctx context.Context) error {
+	err, _ := ctx.Value(requestErrorKey{}).(error)
+	return err
+}
+
+func contextWithClientBefore(ctx context.Context, before []ClientBeforeFunc) context.Context {
+	return context.WithValue(ctx, clientBeforeKey{}, before)
+}
+
+func clientBeforeFromContext(ctx context.Context) []ClientBeforeFunc {
+	before, _ := ctx.Value(clientBeforeKey{}).([]ClientBeforeFunc)
+	return before
+}
+
+type targetKey struct{}
+
+func contextWithTarget(ctx context.Context, target string) context.Context {
+	return context.WithValue(ctx, targetKey{}, target)
+}
+
+type httpTransport struct {
+	target     string
+	httpClient *http.Client
+
+	followRedirects bool
+	maxRedirects    int
+	method          string
+	// ownsConns is set when NewClient cloned httpClient's transport, so
+	// its connections are not shared with other code.
+	ownsConns bool
+}
+
+func (t *httpTransport) RoundTrip(ctx context.Context, payload []byte) (io.ReadCloser, TransportMeta, error) {
+	target := t.target
+	if v, ok := ctx.Value(targetKey{}).(string); ok {
+		target = v
+	}
+	chain := []string{target}
+	for {
+		req, err := t.newRequest(ctx, target, payload)
+		if err != nil {
+			return nil, TransportMeta{Request: req}, err
+		}
+		resp, err := t.httpClient.Do(req)
+		if err != nil {
+			return nil, TransportMeta{Request: req}, err
+		}
+		meta := TransportMeta{Request: req, Response: resp}
+		if t.followRedirects && isRedirect(resp.StatusCode) {
+			_ = resp.Body.Close()
+			loc, err := resp.Location()
+			if err != nil {
+				return nil, meta, err
+			}
+			chain = append(chain, loc.String())
+			if len(chain)-1 > t.maxRedirects {
+				return nil, meta, fmt.Errorf("jsonrpc: stopped after %d redirects: %s", t.maxRedirects, strings.Join(chain, " -> "))
+			}
+			target = loc.String()
+			continue
+		}
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+			_ = resp.Body.Close()
+			return nil, meta, errors.New(resp.Status)
+		}
+		return resp.Body, meta, nil
+	}
+}
+
+// newRequest builds the request for target and runs the before funcs on
+// it. When one of them aborts, the request is returned with the error so
+// its context still reaches the observers.
+func (t *httpTransport) newRequest(ctx context.Context, target string, payload []byte) (*http.Request, error) {
+	method := "POST"
+	if t.method == http.MethodGet {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if method == http.MethodGet {
+		query, err := payloadQuery(payload)
+		if err != nil {
+			return nil, err
+		}
+		if req.URL.RawQuery != "" {
+			query = req.URL.RawQuery + "&" + query
+		}
+		req.URL.RawQuery = query
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(payload)), nil
+		}
+		req.Body, _ = req.GetBody()
+		req.ContentLength = int64(len(payload))
+	}
+	req.Header.Set("Accept", "application/json")
+	for _, beforeFunc := range clientBeforeFromContext(ctx) {
+		req = req.WithContext(beforeFunc(req.Context(), req))
+		if err := requestErrorFromContext(req.Context()); err != nil {
+			return req, err
+		}
+	}
+	if req.GetBody != nil {
+		req.Body, _ = req.GetBody()
+	}
+	return req, nil
+}
+
+// payloadQuery turns a single encoded request into the query parameters of
+// a GET call, with params kept as JSON.
+func payloadQuery(payload []byte) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return "", fmt.Errorf("jsonrpc: GET requests must be sent one at a time: %w", err)
+	}
+	query := url.Values{}
+	for name, value := range fields {
+		var s string
+		if name != "params" && json.Unmarshal(value, &s) == nil {
+			query.Set(name, s)
+		} else {
+			query.Set(name, string(value))
+		}
+	}
+	return query.Encode(), nil
+}
+
+// WithHTTPMethod selects the HTTP method of calls. With "GET" each request
+// is sent on its own as query parameters jsonrpc, method, id and params,
+// the latter as JSON. The default is POST.
+func WithHTTPMethod(method string) ClientOption {
+	return func(o *clientOptions) {
+		o.httpMethod = method
+	}
+}
+
+func isRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// WithRedirects makes the client follow up to max redirects itself,
+// sending the same encoded body again as a POST to each Location instead
+// of relying on the http.Client's policy. BeforeRequest hooks run again
+// for every redirected request. Going past max fails with the whole
+// redirect chain in the error.
+func WithRedirects(max int) ClientOption {
+	return func(o *clientOptions) {
+		o.followRedirects = true
+		o.maxRedirects = max
+	}
+}
+
+// Close drops idle connections of a transport NewClient created itself;
+// an http.Client passed in with WithHTTPClient, or http.DefaultClient, may
+// be shared and is left alone.
+func (t *httpTransport) Close() error {
+	if t.ownsConns {
+		t.httpClient.CloseIdleConnections()
+	}
+	return nil
+}
+
+// cloneHTTPTransport clones the http.Client's transport, which has to be
+// an *http.Transport, or http.DefaultTransport when it has none. Any other
+// RoundTripper is refused rather than replaced.
+func cloneHTTPTransport(httpClient *http.Client, option string) (*http.Transport, error) {
+	switch t := httpClient.Transport.(type) {
+	case nil:
+		return http.DefaultTransport.(*http.Transport).Clone(), nil
+	case *http.Transport:
+		return t.Clone(), nil
+	default:
+		return nil, fmt.Errorf("jsonrpc: %s needs an *http.Transport, the HTTP client uses %T", option, t)
+	}
+}
+
+// unixTarget rewrites a "unix:///path/to/socket" target into an HTTP URL
+// with a synthetic host and an http.Client that dials the socket.
+func unixTarget(target string, httpClient *http.Client) (string, *http.Client, error) {
+	path, ok := strings.CutPrefix(target, "unix://")
+	if !ok {
+		return target, httpClient, nil
+	}
+	transport, err := cloneHTTPTransport(httpClient, "a unix:// target")
+	if err != nil {
+		return target, httpClient, err
+	}
+	transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", path)
+	}
+	client := *httpClient
+	client.Transport = transport
+	return "http://localhost/", &client, nil
+}
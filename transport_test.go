@@ -0,0 +1,348 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/555f/jsonrpc"
+)
+
+func TestClientUnixSocket(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	path := filepath.Join(t.TempDir(), "rpc.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip(err)
+	}
+	var host string
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		s.ServeHTTP(w, r)
+	}))
+	ts.Listener = l
+	ts.Start()
+	defer ts.Close()
+
+	c := jsonrpc.NewClient("unix://" + path)
+	result, err := c.Execute(&echoRequest{method: "echo", params: "ipc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.At(0); got != "ipc" {
+		t.Fatalf("got %v, want ipc", got)
+	}
+	if host != "localhost" {
+		t.Fatalf("got Host %q, want localhost", host)
+	}
+}
+
+func TestClientContentType(t *testing.T) {
+	var header http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.Write([]byte(`[{"id":1,"jsonrpc":"2.0","result":null}]`))
+	}))
+	defer ts.Close()
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.BeforeRequest(func(ctx context.Context, r *http.Request) context.Context {
+		r.Header.Set("Accept", "application/json-rpc")
+		return ctx
+	}))
+	if _, err := c.Execute(&echoRequest{method: "echo"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get("Content-Type"); got != "application/json" {
+		t.Fatalf("got Content-Type %q", got)
+	}
+	if got := header.Get("Accept"); got != "application/json-rpc" {
+		t.Fatalf("before func could not override Accept, got %q", got)
+	}
+}
+
+func TestClientURLFactory(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		s.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	c := jsonrpc.NewClientWithURLFactory(func(method string) string {
+		if method == "" {
+			return ts.URL + "/rpc/batch"
+		}
+		return ts.URL + "/rpc/" + method
+	})
+	if _, err := c.Execute(&echoRequest{method: "echo", params: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Execute(&echoRequest{method: "echo", params: 1}, &echoRequest{method: "echo", params: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 || paths[0] != "/rpc/echo" || paths[1] != "/rpc/batch" {
+		t.Fatalf("unexpected paths %v", paths)
+	}
+}
+
+func TestClientTLSConfig(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	ts := httptest.NewTLSServer(s)
+	defer ts.Close()
+
+	if _, err := jsonrpc.NewClient(ts.URL).Execute(&echoRequest{method: "echo", params: 1}); err == nil {
+		t.Fatal("untrusted certificate was accepted")
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.WithTLSConfig(&tls.Config{RootCAs: pool}))
+	if _, err := c.Execute(&echoRequest{method: "echo", params: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	custom := &http.Client{Transport: roundTripperFunc(http.DefaultTransport.RoundTrip)}
+	c = jsonrpc.NewClient(ts.URL, jsonrpc.WithHTTPClient(custom), jsonrpc.WithTLSConfig(&tls.Config{RootCAs: pool}))
+	if _, err := c.Execute(&echoRequest{method: "echo", params: 1}); err == nil || !strings.Contains(err.Error(), "WithTLSConfig") {
+		t.Fatalf("got %v, want the custom transport to be refused", err)
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestClientRedirects(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	var hops int
+	mux := http.NewServeMux()
+	mux.Handle("/rpc", s)
+	mux.HandleFunc("/hop/", func(w http.ResponseWriter, r *http.Request) {
+		hops++
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hop/"))
+		next := "/rpc"
+		if n > 1 {
+			next = "/hop/" + strconv.Itoa(n-1)
+		}
+		http.Redirect(w, r, next, http.StatusTemporaryRedirect)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	var tokens int
+	count := func(ctx context.Context, r *http.Request) context.Context {
+		tokens++
+		return ctx
+	}
+	c := jsonrpc.NewClient(ts.URL+"/hop/2", jsonrpc.WithRedirects(2), jsonrpc.BeforeRequest(count))
+	result, err := c.Execute(&echoRequest{method: "echo", params: "kept"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.At(0) != "kept" || hops != 2 || tokens != 3 {
+		t.Fatalf("result %v after %d hops, before funcs ran %d times", result.At(0), hops, tokens)
+	}
+
+	c = jsonrpc.NewClient(ts.URL+"/hop/3", jsonrpc.WithRedirects(2))
+	_, err = c.Execute(&echoRequest{method: "echo"})
+	if err == nil || !strings.Contains(err.Error(), "/hop/3 -> "+ts.URL+"/hop/2 -> "+ts.URL+"/hop/1") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+type targetRequest struct {
+	echoRequest
+	target string
+}
+
+func (r *targetRequest) Target() string {
+	return r.target
+}
+
+func TestClientRequesterWithTarget(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	var calls atomic.Int32
+	archive := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		s.ServeHTTP(w, r)
+	}))
+	defer archive.Close()
+	full := httptest.NewServer(s)
+	defer full.Close()
+
+	c := jsonrpc.NewClient(full.URL)
+	result, err := c.Execute(
+		&echoRequest{method: "echo", params: "a"},
+		&targetRequest{echoRequest{method: "echo", params: "b"}, archive.URL},
+		&echoRequest{method: "echo", params: "c"},
+		&targetRequest{echoRequest{method: "echo", params: "d"}, archive.URL},
+		&targetRequest{echoRequest{method: "echo", params: "e"}, "http://127.0.0.1:1"},
+	)
+	var groupErrs jsonrpc.GroupErrors
+	if !errors.As(err, &groupErrs) || groupErrs[0] != nil || groupErrs[2] == nil {
+		t.Fatalf("got %v, want GroupErrors for the unreachable target", err)
+	}
+	for i, want := range []string{"a", "b", "c", "d"} {
+		if got := result.At(i); got != want {
+			t.Fatalf("result %d = %v, want %s", i, got, want)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("archive got %d calls, want 1", calls.Load())
+	}
+	if !errors.Is(result.Error(4), jsonrpc.ErrChunkFailed) || result.Error(0) != nil {
+		t.Fatalf("errors = %v", result.Errors())
+	}
+}
+
+func TestClientLoadBalancer(t *testing.T) {
+	var hits [2]atomic.Int32
+	var urls []string
+	for i := range hits {
+		i := i
+		s := jsonrpc.NewServer()
+		s.Register("echo", echoEndpoint, rawDecode)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[i].Add(1)
+			s.ServeHTTP(w, r)
+		}))
+		defer ts.Close()
+		urls = append(urls, ts.URL)
+	}
+
+	for _, newBalancer := range []func([]string) (jsonrpc.LoadBalancer, error){jsonrpc.NewRoundRobinBalancer, jsonrpc.NewRandomBalancer} {
+		if _, err := newBalancer(nil); !errors.Is(err, jsonrpc.ErrNoTargets) {
+			t.Fatalf("got %v, want ErrNoTargets", err)
+		}
+	}
+	lb, err := jsonrpc.NewRoundRobinBalancer(urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := jsonrpc.NewClient("http://127.0.0.1:1", jsonrpc.WithLoadBalancer(lb))
+	for i := 0; i < 4; i++ {
+		if _, err := c.Execute(&echoRequest{method: "echo", params: "x"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hits[0].Load() != 2 || hits[1].Load() != 2 {
+		t.Fatalf("hits = %d, %d, want 2 each", hits[0].Load(), hits[1].Load())
+	}
+}
+
+func TestExecuteWithFallback(t *testing.T) {
+	ts, calls := newCountingServer(t)
+	primary := jsonrpc.NewClient("http://127.0.0.1:1")
+	fallback := jsonrpc.NewClient(ts.URL)
+
+	result, err := jsonrpc.ExecuteWithFallback(context.Background(), primary, fallback, &echoRequest{method: "echo", params: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.At(0) != "x" || *calls != 1 {
+		t.Fatalf("result %v after %d fallback calls", result.At(0), *calls)
+	}
+
+	result, err = jsonrpc.ExecuteWithFallback(context.Background(), fallback, primary, &echoRequest{method: "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Error(0) == nil || *calls != 2 {
+		t.Fatalf("JSON-RPC error triggered the fallback")
+	}
+}
+
+func TestClientCancelDuringBodyRead(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":1,"jsonrpc":"2.0","result":"`))
+		w.(http.Flusher).Flush()
+		for i := 0; i < 100; i++ {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+			w.Write([]byte("x"))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := jsonrpc.NewClient(ts.URL).ExecuteWithContext(ctx, &echoRequest{method: "echo"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Execute returned after %v", elapsed)
+	}
+}
+
+func TestClientHTTPMethodGet(t *testing.T) {
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("got method %s", r.Method)
+		}
+		q := r.URL.Query()
+		queries = append(queries, r.URL.RawQuery)
+		w.Write([]byte(`{"id":` + q.Get("id") + `,"jsonrpc":"2.0","result":` + q.Get("params") + `}`))
+	}))
+	defer ts.Close()
+
+	c := jsonrpc.NewClient(ts.URL+"?key=k", jsonrpc.WithHTTPMethod(http.MethodGet))
+	result, err := c.Execute(&echoRequest{method: "echo", params: []int{1}}, &echoRequest{method: "echo", params: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 2 || result.At(1) != "b" {
+		t.Fatalf("queries %q, results %v %v", queries, result.At(0), result.At(1))
+	}
+	if queries[0] != "key=k&id=1&jsonrpc=2.0&method=echo&params=%5B1%5D" {
+		t.Fatalf("unexpected query %s", queries[0])
+	}
+
+	strict := jsonrpc.NewClient(ts.URL, jsonrpc.WithHTTPMethod(http.MethodGet), jsonrpc.WithStrictResponses())
+	if result, err := strict.Execute(&echoRequest{method: "echo", params: "strict"}); err != nil || result.At(0) != "strict" {
+		t.Fatalf("strict GET got %v, %v", result, err)
+	}
+}
+
+func TestClientBeforeSeesBody(t *testing.T) {
+	var header, body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("X-Body-Length")
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.Write([]byte(`[{"id":1,"jsonrpc":"2.0","result":null}]`))
+	}))
+	defer ts.Close()
+	c := jsonrpc.NewClient(ts.URL, jsonrpc.BeforeRequest(func(ctx context.Context, r *http.Request) context.Context {
+		data, _ := io.ReadAll(r.Body)
+		r.Header.Set("X-Body-Length", strconv.Itoa(len(data)))
+		return ctx
+	}))
+	if _, err := c.Execute(&echoRequest{method: "echo", params: "signed"}); err != nil {
+		t.Fatal(err)
+	}
+	if header == "0" || header != strconv.Itoa(len(body)) {
+		t.Fatalf("before func saw %s bytes, server got %d", header, len(body))
+	}
+}
@@ -0,0 +1,31 @@
+package jsonrpc
+
+import "context"
+
+// ValidateParams returns endpoint middleware that runs validate on the
+// decoded request and answers with Invalid Params when it fails. The
+// error message becomes the data; errors joined with errors.Join, as most
+// validators can produce one per field, become a list of messages.
+func ValidateParams(validate func(any) error) EndpointMiddlewareFunc {
+	return func(next Endpoint) Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			if err := validate(request); err != nil {
+				return nil, NewError(jsonRPCInvalidParamsError, "invalid params", validationData(err))
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+func validationData(err error) any {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return err.Error()
+	}
+	errs := joined.Unwrap()
+	details := make([]string, len(errs))
+	for i, err := range errs {
+		details[i] = err.Error()
+	}
+	return details
+}
@@ -0,0 +1,60 @@
+package jsonrpc
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+type wsConn struct {
+	conn *websocket.Conn
+}
+
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	_, data, err := c.conn.ReadMessage()
+	return data, err
+}
+
+func (c *wsConn) WriteMessage(data []byte) error {
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (c *wsConn) Close() error {
+	_ = c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	return c.conn.Close()
+}
+
+func NewWSClient(url string, opts ...ClientOption) (*Client, error) {
+	c := NewClient(url, opts...)
+	ctx := c.opts.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	t := c.newMuxTransport(func(ctx context.Context) (messageConn, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, beforeFunc := range c.opts.before {
+			req = req.WithContext(beforeFunc(req.Context(), req))
+			if err := requestErrorFromContext(req.Context()); err != nil {
+				return nil, err
+			}
+		}
+		conn, resp, err := websocket.DefaultDialer.DialContext(ctx, url, req.Header)
+		if resp != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &wsConn{conn: conn}, nil
+	}, true)
+	if _, err := t.connect(ctx); err != nil {
+		return nil, err
+	}
+	c.opts.transport = t
+	c.ownsTransport = true
+	return c, nil
+}
@@ -0,0 +1,136 @@
+package jsonrpc_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/555f/jsonrpc"
+	"github.com/gorilla/websocket"
+)
+
+func newWSServer(t *testing.T, s *jsonrpc.Server, onHandshake func(r *http.Request)) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if onHandshake != nil {
+			onHandshake(r)
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var mu sync.Mutex
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			go func() {
+				rec := httptest.NewRecorder()
+				s.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(string(data))))
+				mu.Lock()
+				defer mu.Unlock()
+				_ = conn.WriteMessage(websocket.TextMessage, rec.Body.Bytes())
+			}()
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestWSClient(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	var token string
+	ts := newWSServer(t, s, func(r *http.Request) {
+		token = r.Header.Get("Authorization")
+	})
+	c, err := jsonrpc.NewWSClient("ws"+strings.TrimPrefix(ts.URL, "http"), jsonrpc.BeforeRequest(func(ctx context.Context, r *http.Request) context.Context {
+		r.Header.Set("Authorization", "Bearer secret")
+		return ctx
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if token != "Bearer secret" {
+		t.Fatalf("handshake header not applied, got %q", token)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := c.Execute(&echoRequest{method: "echo", params: fmt.Sprint(i)}, &echoRequest{method: "echo", params: "x"})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got := result.At(0); got != fmt.Sprint(i) {
+				t.Errorf("got %v, want %d", got, i)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Execute(&echoRequest{method: "echo", params: "late"}); err == nil {
+		t.Fatal("expected error after Close")
+	}
+}
+
+func TestWSClientReconnect(t *testing.T) {
+	s := jsonrpc.NewServer()
+	s.Register("echo", echoEndpoint, rawDecode)
+	var conns []*websocket.Conn
+	var mu sync.Mutex
+	upgrader := websocket.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		conns = append(conns, conn)
+		first := len(conns) == 1
+		mu.Unlock()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if first {
+				conn.Close()
+				return
+			}
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(string(data))))
+			_ = conn.WriteMessage(websocket.TextMessage, rec.Body.Bytes())
+		}
+	}))
+	defer ts.Close()
+
+	c, err := jsonrpc.NewWSClient("ws" + strings.TrimPrefix(ts.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Execute(&echoRequest{method: "echo", params: "lost"}); err == nil {
+		t.Fatal("expected in-flight call to fail when the connection drops")
+	}
+	result, err := c.Execute(&echoRequest{method: "echo", params: "again"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.At(0); got != "again" {
+		t.Fatalf("got %v, want again", got)
+	}
+}